@@ -17,6 +17,8 @@ limitations under the License.
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -65,10 +67,73 @@ const (
 	PostgreSqlDatabaseProvider DatabaseProvider = "postgresql"
 )
 
+// DeletionPolicy specifies what happens to the data-bearing resources when the Config is deleted
+// +kubebuilder:validation:Enum=Retain;Delete
+type DeletionPolicy string
+
+const (
+	// RetainDeletionPolicy is a DeletionPolicy, the data-bearing resources are kept
+	RetainDeletionPolicy DeletionPolicy = "Retain"
+
+	// DeleteDeletionPolicy is a DeletionPolicy, the data-bearing resources are deleted
+	DeleteDeletionPolicy DeletionPolicy = "Delete"
+)
+
+// PatchType specifies how a patch is applied to the rendered objects
+// +kubebuilder:validation:Enum=StrategicMerge;JSON6902
+type PatchType string
+
+const (
+	// StrategicMergePatchType is a PatchType, the patch is a partial object merged into the rendered object.
+	// The kinds without strategic merge metadata, such as the custom resources, are patched with a JSON merge patch.
+	StrategicMergePatchType PatchType = "StrategicMerge"
+
+	// JSON6902PatchType is a PatchType, the patch is a list of JSON patch operations
+	JSON6902PatchType PatchType = "JSON6902"
+)
+
+// LeafHubLabelKey is the label of ManagedCluster that marks the cluster as a leaf hub,
+// the hub-of-hubs agent is deployed to the managed clusters that have this label with value "true"
+const LeafHubLabelKey = "hubofhubs.open-cluster-management.io/leaf-hub"
+
 // ConfigSpec defines the desired state of Config
 type ConfigSpec struct {
 	Global     *GlobalConfig     `json:"global,omitempty"`
 	Components *ComponentsConfig `json:"components,omitempty"`
+	// DeletionPolicy specifies whether the data-bearing resources, such as the database and the kafka cluster, are
+	// deleted together with the Config
+	// +kubebuilder:default:=Retain
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+	// Patches are applied in order to the rendered objects of the components in the hub-of-hubs cluster
+	// before they are deployed, a component is not deployed if one of its patches fails
+	Patches []PatchConfig `json:"patches,omitempty"`
+}
+
+// PatchConfig defines a patch of the rendered objects, e.g. an env var of the manager or a toleration
+// of the Kafka pods
+type PatchConfig struct {
+	// Target selects the rendered objects that are patched
+	Target PatchTarget `json:"target"`
+	// Type is the type of the patch
+	// +kubebuilder:default:=StrategicMerge
+	Type PatchType `json:"type,omitempty"`
+	// Patch is the patch in YAML or JSON, a partial object for StrategicMerge
+	// or a list of operations for JSON6902
+	// +kubebuilder:validation:MinLength=1
+	Patch string `json:"patch"`
+}
+
+// PatchTarget selects rendered objects by group, kind, name and namespace
+type PatchTarget struct {
+	// Group is the API group of the objects, empty for the core group
+	Group string `json:"group,omitempty"`
+	// Kind is the kind of the objects
+	// +kubebuilder:validation:MinLength=1
+	Kind string `json:"kind"`
+	// Name is the name of the objects, all the objects of the kind are patched when it is empty
+	Name string `json:"name,omitempty"`
+	// Namespace is the namespace of the objects, the objects of all the namespaces are patched when it is empty
+	Namespace string `json:"namespace,omitempty"`
 }
 
 // GlobalConfig defines common settings
@@ -78,6 +143,28 @@ type GlobalConfig struct {
 	HeartbeatInterval *HeartbeatIntervalConfig `json:"heartbeatInterval,omitempty"`
 	// +kubebuilder:default:=true
 	EnableLocalPolicies bool `json:"enableLocalPolicies,omitempty"`
+	// ImageRegistry is the registry of the hub-of-hubs images, the PostgreSQL images are also pulled from it
+	// when it's not the default registry
+	// +kubebuilder:default:="quay.io/open-cluster-management-hub-of-hubs"
+	ImageRegistry string `json:"imageRegistry,omitempty"`
+	// +kubebuilder:default:=latest
+	ImageTag string `json:"imageTag,omitempty"`
+	// ImagePullSecrets are added to all the hub-of-hubs pods, the secrets are read from the namespace of the Config
+	// and copied to the namespaces of the pods and to the leaf hubs with the agent
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+	ImageOverrides   *ImageOverridesConfig         `json:"imageOverrides,omitempty"`
+}
+
+// ImageOverridesConfig defines full image references that override the images of individual components
+type ImageOverridesConfig struct {
+	Manager        string `json:"manager,omitempty"`
+	Agent          string `json:"agent,omitempty"`
+	DatabaseInit   string `json:"databaseInit,omitempty"`
+	Postgres       string `json:"postgres,omitempty"`
+	PgBackRest     string `json:"pgBackRest,omitempty"`
+	PgBouncer      string `json:"pgBouncer,omitempty"`
+	SyncServiceCSS string `json:"syncServiceCSS,omitempty"`
+	SyncServiceESS string `json:"syncServiceESS,omitempty"`
 }
 
 // HeartbeatIntervalConfig defines heartbeat intervals for HoH and Leaf hub in seconds
@@ -113,9 +200,39 @@ type HohConfig struct {
 
 // Nonk8sAPIConfig defines settings for nonk8s-API
 type Nonk8sAPIConfig struct {
+	// BasePath is the path of the API, the default is /multicloud/hub-of-hubs-nonk8s-api
+	// +kubebuilder:validation:Pattern=`^/[-a-zA-Z0-9_./]*$`
 	BasePath string `json:"basePath,omitempty"`
+	// Exposure is how the API is exposed outside of the cluster
+	// +kubebuilder:default:=Ingress
+	Exposure Nonk8sAPIExposure `json:"exposure,omitempty"`
+	// Host is the host of the ingress or of the route, any host is matched by the ingress when it is not set
+	// and the route gets a generated host
+	Host string `json:"host,omitempty"`
+	// IngressClassName is the class of the ingress, the default is ingress-open-cluster-management
+	IngressClassName string `json:"ingressClassName,omitempty"`
+	// TLSSecret is the secret with the certificate of the host of the ingress, it is not supported by routes
+	TLSSecret *corev1.LocalObjectReference `json:"tlsSecret,omitempty"`
+	// Annotations are set on the ingress or the route instead of the default annotations, which make
+	// the management ingress check the access token of the requests
+	Annotations map[string]string `json:"annotations,omitempty"`
 }
 
+// Nonk8sAPIExposure specifies how the nonk8s-API is exposed
+// +kubebuilder:validation:Enum=Ingress;Route;None
+type Nonk8sAPIExposure string
+
+const (
+	// IngressNonk8sAPIExposure is a Nonk8sAPIExposure, the API is exposed with an ingress
+	IngressNonk8sAPIExposure Nonk8sAPIExposure = "Ingress"
+
+	// RouteNonk8sAPIExposure is a Nonk8sAPIExposure, the API is exposed with an OpenShift route
+	RouteNonk8sAPIExposure Nonk8sAPIExposure = "Route"
+
+	// NoneNonk8sAPIExposure is a Nonk8sAPIExposure, the API is only reachable inside of the cluster
+	NoneNonk8sAPIExposure Nonk8sAPIExposure = "None"
+)
+
 // RBACConfig defines settings for RBAC
 type RBACConfig struct {
 }
@@ -168,7 +285,8 @@ type LeafHubStatusSyncConfig struct {
 	DeltaSentCountSwitchFactor uint64 `default:"100" json:"deltaSentCountSwitchFactor,omitempty"`
 	// +kubebuilder:default:=gzip
 	MsgCompressType MsgCompressType `json:"msgCompressType,omitempty"` // gzip or no-op
-	MsgSizeLimit    uint64          `default:"940" json:"msgSizeLimit,omitempty"`
+	// +kubebuilder:default:=940
+	MsgSizeLimit uint64 `default:"940" json:"msgSizeLimit,omitempty"`
 }
 
 // LeafHubStatusSyncIntervalSettings defines snyc interval settings for leahub-status-sync
@@ -191,16 +309,155 @@ type TransportConfig struct {
 
 // KafkaConfig defines settings for Kafka transport
 type KafkaConfig struct {
+	// Version is the Kafka version deployed by Strimzi, the default is 2.7.0
+	// +kubebuilder:validation:Pattern=`^[0-9]+\.[0-9]+\.[0-9]+$`
 	Version string `json:"version,omitempty"`
+	// Replicas is the number of brokers, the replication factor of the topics is derived from it
 	// +kubebuilder:default:=3
+	// +kubebuilder:validation:Minimum=1
 	Replicas uint64 `default:"3" json:"replicas,omitempty"`
+	// ZooKeeperReplicas is the number of ZooKeeper nodes
+	// +kubebuilder:default:=3
+	// +kubebuilder:validation:Minimum=1
+	ZooKeeperReplicas uint64 `default:"3" json:"zookeeperReplicas,omitempty"`
+	// Storage defines the persistent volumes of the brokers and of ZooKeeper,
+	// ephemeral storage is used when it is not set
+	Storage *KafkaStorageConfig `json:"storage,omitempty"`
+	// Resources are the compute resources of each broker
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+	// Topics are the topics created in the Kafka cluster. The spec and status topics are always created,
+	// with 1 partition and the compact cleanup policy unless they are declared here.
+	// +listType=map
+	// +listMapKey=name
+	Topics []KafkaTopicConfig `json:"topics,omitempty"`
+	// External connects hub-of-hubs to an existing Kafka cluster instead of deploying one with Strimzi,
+	// the other Kafka settings are ignored when it is set
+	External *ExternalKafkaConfig `json:"external,omitempty"`
+}
+
+// KafkaTopicConfig defines a topic of the Kafka cluster deployed with Strimzi
+type KafkaTopicConfig struct {
+	// Name is the name of the topic and of its KafkaTopic resource
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9.]*[a-z0-9])?$`
+	// +kubebuilder:validation:MaxLength=249
+	Name string `json:"name"`
+	// Partitions is the number of partitions of the topic, it can be increased but not decreased
+	// +kubebuilder:default:=1
+	// +kubebuilder:validation:Minimum=1
+	Partitions uint64 `default:"1" json:"partitions,omitempty"`
+	// Replicas is the replication factor of the topic, it is derived from the number of brokers when it is not set
+	Replicas uint64 `json:"replicas,omitempty"`
+	// RetentionMs is the retention time of the messages in milliseconds, -1 means no limit
+	// +kubebuilder:validation:Minimum=-1
+	RetentionMs *int64 `json:"retentionMs,omitempty"`
+	// +kubebuilder:default:=compact
+	CleanupPolicy KafkaTopicCleanupPolicy `json:"cleanupPolicy,omitempty"`
+}
+
+// KafkaTopicCleanupPolicy specifies how the old messages of a Kafka topic are removed
+// +kubebuilder:validation:Enum=compact;delete;"compact,delete"
+type KafkaTopicCleanupPolicy string
+
+const (
+	// CompactKafkaTopicCleanupPolicy is a KafkaTopicCleanupPolicy, only the last message of each key is retained
+	CompactKafkaTopicCleanupPolicy KafkaTopicCleanupPolicy = "compact"
+
+	// DeleteKafkaTopicCleanupPolicy is a KafkaTopicCleanupPolicy, the messages are deleted after the retention time
+	DeleteKafkaTopicCleanupPolicy KafkaTopicCleanupPolicy = "delete"
+
+	// CompactDeleteKafkaTopicCleanupPolicy is a KafkaTopicCleanupPolicy, the messages are compacted and
+	// deleted after the retention time
+	CompactDeleteKafkaTopicCleanupPolicy KafkaTopicCleanupPolicy = "compact,delete"
+)
+
+// RequiredKafkaTopics are the topics used by the hub-of-hubs components
+var RequiredKafkaTopics = []string{"spec", "status"}
+
+// GetTopics returns the declared topics followed by the required topics that are not declared,
+// with the defaults of the unset fields
+func (c *KafkaConfig) GetTopics() []KafkaTopicConfig {
+	var topics []KafkaTopicConfig
+	declared := map[string]bool{}
+	if c != nil {
+		for _, topic := range c.Topics {
+			declared[topic.Name] = true
+			topics = append(topics, topic)
+		}
+	}
+	for _, name := range RequiredKafkaTopics {
+		if !declared[name] {
+			topics = append(topics, KafkaTopicConfig{Name: name})
+		}
+	}
+
+	for i := range topics {
+		if topics[i].Partitions == 0 {
+			topics[i].Partitions = 1
+		}
+		if topics[i].CleanupPolicy == "" {
+			topics[i].CleanupPolicy = CompactKafkaTopicCleanupPolicy
+		}
+	}
+	return topics
+}
+
+// KafkaStorageConfig defines the persistent-claim storage of Kafka. The volumes can be expanded if their
+// storage class allows it, but they can't be shrunk, their storage class can't be changed and the storage
+// can't be switched between ephemeral and persistent.
+type KafkaStorageConfig struct {
+	// Size is the size of the volume of each broker and ZooKeeper node
+	Size resource.Quantity `json:"size"`
+	// StorageClassName is the storage class of the volumes, the default storage class is used when it is not set
+	StorageClassName *string `json:"storageClassName,omitempty"`
 }
 
-// SyncServiceConfig defines settings for Sync-service transport
+// KafkaAuthenticationType specifies how the hub-of-hubs components authenticate to Kafka
+// +kubebuilder:validation:Enum=tls;scram-sha-256;scram-sha-512
+type KafkaAuthenticationType string
+
+const (
+	// TLSKafkaAuthenticationType is a KafkaAuthenticationType, the clients use mutual TLS
+	TLSKafkaAuthenticationType KafkaAuthenticationType = "tls"
+
+	// ScramSha256KafkaAuthenticationType is a KafkaAuthenticationType, the clients use SASL/SCRAM-SHA-256
+	ScramSha256KafkaAuthenticationType KafkaAuthenticationType = "scram-sha-256"
+
+	// ScramSha512KafkaAuthenticationType is a KafkaAuthenticationType, the clients use SASL/SCRAM-SHA-512
+	ScramSha512KafkaAuthenticationType KafkaAuthenticationType = "scram-sha-512"
+)
+
+// ExternalKafkaConfig defines the connection to an existing Kafka cluster, the spec and status topics
+// must exist in the cluster. The secrets must be in the namespace of the Config.
+type ExternalKafkaConfig struct {
+	// BootstrapServers are the addresses of the brokers used to connect to the cluster
+	// +kubebuilder:validation:MinItems=1
+	BootstrapServers []string `json:"bootstrapServers"`
+	// CASecret is the secret with the CA certificate of the brokers in the ca.crt key,
+	// the connection uses TLS when it is set or when the authentication type is tls
+	CASecret *corev1.LocalObjectReference `json:"caSecret,omitempty"`
+	// Authentication defines the credentials of the clients, the clients are anonymous when it is not set
+	Authentication *KafkaAuthenticationConfig `json:"authentication,omitempty"`
+}
+
+// KafkaAuthenticationConfig defines the client credentials of Kafka
+type KafkaAuthenticationConfig struct {
+	Type KafkaAuthenticationType `json:"type"`
+	// Secret is the secret with the credentials, the tls.crt and tls.key keys for tls authentication
+	// or the username and password keys for SCRAM authentication
+	Secret corev1.LocalObjectReference `json:"secret"`
+}
+
+// SyncServiceConfig defines settings for Sync-service transport. The CSS listens with TLS, its certificates
+// are generated by the operator, and each ESS authenticates to the CSS with the credentials of its leaf hub.
 type SyncServiceConfig struct {
+	// Version is the image tag of the CSS and the ESS, the default is stable
 	Version string `json:"version,omitempty"`
+	// PollingInterval is the interval in seconds of the ESS polling the CSS
 	// +kubebuilder:default:=5
+	// +kubebuilder:validation:Minimum=1
 	PollingInterval uint64 `default:"5" json:"pollingInterval,omitempty"`
+	// Host is the host of the route of the CSS, the route gets a generated host when it is not set
+	Host string `json:"host,omitempty"`
 }
 
 // DatabaseConfig defines settings for database
@@ -213,14 +470,147 @@ type DatabaseConfig struct {
 type PostgreSqlConfig struct {
 	Version  string `json:"version,omitempty"`
 	EnableHA bool   `json:"enableHA,omitempty"`
+	// Replicas is the number of PostgreSQL instances, at least 2 replicas are required when HA is enabled
+	Replicas uint64 `json:"replicas,omitempty"`
+	// Storage defines the volumes of the PostgreSQL instances and of the pgBackRest repository
+	Storage *PostgreSqlStorageConfig `json:"storage,omitempty"`
+	// Resources are the compute resources of each PostgreSQL instance
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+	// External connects hub-of-hubs to an existing PostgreSQL database instead of deploying one
+	External *ExternalPostgreSqlConfig `json:"external,omitempty"`
+}
+
+// DefaultPostgreSqlVolumeSize is the size of the data and backup volumes of PostgreSQL when it is not set
+const DefaultPostgreSqlVolumeSize = "50Gi"
+
+// PostgreSqlStorageConfig defines the volumes of PostgreSql. The volumes can be expanded if their storage class
+// allows it, but they can't be shrunk and their storage class can't be changed.
+type PostgreSqlStorageConfig struct {
+	// DataSize is the size of the data volume of each instance, the default is 50Gi
+	DataSize *resource.Quantity `json:"dataSize,omitempty"`
+	// BackupSize is the size of the volume of the pgBackRest repository, the default is 50Gi
+	BackupSize *resource.Quantity `json:"backupSize,omitempty"`
+	// StorageClassName is the storage class of the volumes, the default storage class is used when it is not set
+	StorageClassName *string `json:"storageClassName,omitempty"`
 }
 
+// GetDataSize returns the size of the data volume, or the default size if it is not set
+func (c *PostgreSqlStorageConfig) GetDataSize() resource.Quantity {
+	if c == nil || c.DataSize == nil {
+		return resource.MustParse(DefaultPostgreSqlVolumeSize)
+	}
+	return *c.DataSize
+}
+
+// GetBackupSize returns the size of the backup volume, or the default size if it is not set
+func (c *PostgreSqlStorageConfig) GetBackupSize() resource.Quantity {
+	if c == nil || c.BackupSize == nil {
+		return resource.MustParse(DefaultPostgreSqlVolumeSize)
+	}
+	return *c.BackupSize
+}
+
+// GetStorageClassName returns the storage class of the volumes, or an empty string if it is not set
+func (c *PostgreSqlStorageConfig) GetStorageClassName() string {
+	if c == nil || c.StorageClassName == nil {
+		return ""
+	}
+	return *c.StorageClassName
+}
+
+// ExternalPostgreSqlConfig defines the connection to an existing PostgreSQL database. The secrets must be in
+// the namespace of the Config and have the keys of the Crunchy PGO user secrets: host, port, user, password,
+// dbname and optionally uri.
+type ExternalPostgreSqlConfig struct {
+	// ProcessUserSecret is the connection secret of the user of the hub-of-hubs process
+	ProcessUserSecret corev1.LocalObjectReference `json:"processUserSecret"`
+	// TransportBridgeUserSecret is the connection secret of the user of the transport bridge
+	TransportBridgeUserSecret corev1.LocalObjectReference `json:"transportBridgeUserSecret"`
+	// AdminSecret is the connection secret of the user that initializes the database schemas,
+	// the process user secret is used when it is not set
+	AdminSecret *corev1.LocalObjectReference `json:"adminSecret,omitempty"`
+}
+
+// ConfigPhase specifies the overall phase of the hub-of-hubs installation
+type ConfigPhase string
+
+const (
+	// ConfigPhaseProgressing is a ConfigPhase, the components are being installed or updated
+	ConfigPhaseProgressing ConfigPhase = "Progressing"
+
+	// ConfigPhaseRunning is a ConfigPhase, all the components are installed
+	ConfigPhaseRunning ConfigPhase = "Running"
+
+	// ConfigPhaseFailed is a ConfigPhase, at least one component failed to install
+	ConfigPhaseFailed ConfigPhase = "Failed"
+
+	// ConfigPhaseUninstalling is a ConfigPhase, the Config is deleted and the components are being uninstalled
+	ConfigPhaseUninstalling ConfigPhase = "Uninstalling"
+)
+
+const (
+	// ConditionTypeDatabase reports the status of the database component
+	ConditionTypeDatabase = "Database"
+
+	// ConditionTypeDatabaseInitialized reports the status of the initialization of the database schema
+	ConditionTypeDatabaseInitialized = "DatabaseInitialized"
+
+	// ConditionTypeTransport reports the status of the transport component
+	ConditionTypeTransport = "Transport"
+
+	// ConditionTypeHubConfig reports the status of the hub-of-hubs Config read by the manager
+	ConditionTypeHubConfig = "HubConfig"
+
+	// ConditionTypeManager reports the status of the hub-of-hubs manager component
+	ConditionTypeManager = "Manager"
+
+	// ConditionTypeLeafHubAgents reports the status of the agents in the leaf hubs
+	ConditionTypeLeafHubAgents = "LeafHubAgents"
+
+	// ConditionTypePatchesApplied reports whether the patches of the spec were applied to the rendered objects,
+	// it is false when a patch fails or matches no object
+	ConditionTypePatchesApplied = "PatchesApplied"
+
+	// ConditionTypeReady reports whether all the hub-of-hubs components are ready
+	ConditionTypeReady = "Ready"
+)
+
 // ConfigStatus defines the observed state of Config
 type ConfigStatus struct {
+	// ObservedGeneration is the most recent generation of the Config observed by the operator
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Phase is the overall phase of the hub-of-hubs installation
+	Phase ConfigPhase `json:"phase,omitempty"`
+	// Conditions contains the status of each component and the overall readiness
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+	// LeafHubAgents contains the apply status of the hub-of-hubs agent in each leaf hub
+	LeafHubAgents []LeafHubAgentStatus `json:"leafHubAgents,omitempty"`
+	// KafkaTopics contains the readiness of each topic of the Kafka cluster deployed with Strimzi
+	KafkaTopics []KafkaTopicStatus `json:"kafkaTopics,omitempty"`
+}
+
+// KafkaTopicStatus defines the status of a topic of the Kafka cluster
+type KafkaTopicStatus struct {
+	// Name is the name of the topic
+	Name string `json:"name"`
+	// Conditions contains the Ready condition of the topic
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// LeafHubAgentStatus defines the status of the hub-of-hubs agent deployed to a leaf hub
+type LeafHubAgentStatus struct {
+	// Name is the name of the leaf hub managed cluster
+	Name string `json:"name"`
+	// Conditions are the conditions of the ManifestWork that deploys the agent to the leaf hub
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // Config is the Schema for the configs API
 type Config struct {
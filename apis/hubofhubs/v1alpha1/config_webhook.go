@@ -0,0 +1,390 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"sigs.k8s.io/yaml"
+)
+
+// configlog is for logging in this package.
+var configlog = logf.Log.WithName("config-resource")
+
+// supportedPostgreSqlVersions are the PostgreSQL major versions that can be deployed by the operator
+var supportedPostgreSqlVersions = sets.NewString("13")
+
+// configValidator validates the Config resources, it needs a client to check the existing Config resources
+type configValidator struct {
+	client client.Client
+}
+
+// SetupWebhookWithManager sets up the validating webhook of Config with the manager
+func (r *Config) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		WithValidator(&configValidator{client: mgr.GetClient()}).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-hubofhubs-open-cluster-management-io-v1alpha1-config,mutating=false,failurePolicy=fail,sideEffects=None,groups=hubofhubs.open-cluster-management.io,resources=configs,verbs=create;update,versions=v1alpha1,name=vconfig.hubofhubs.open-cluster-management.io,admissionReviewVersions=v1
+
+var _ admission.CustomValidator = &configValidator{}
+
+// ValidateCreate implements admission.CustomValidator so a webhook will be registered for the type
+func (v *configValidator) ValidateCreate(ctx context.Context, obj runtime.Object) error {
+	config, ok := obj.(*Config)
+	if !ok {
+		return fmt.Errorf("expected a Config but got a %T", obj)
+	}
+	configlog.Info("validate create", "name", config.Name)
+
+	allErrs := config.validateSpec()
+
+	configList := &ConfigList{}
+	if err := v.client.List(ctx, configList); err != nil {
+		return err
+	}
+	for _, existing := range configList.Items {
+		if existing.Namespace != config.Namespace || existing.Name != config.Name {
+			allErrs = append(allErrs, field.Forbidden(field.NewPath("metadata", "name"),
+				fmt.Sprintf("only one Config is allowed in the cluster, found %s/%s",
+					existing.Namespace, existing.Name)))
+			break
+		}
+	}
+
+	return config.toInvalidError(allErrs)
+}
+
+// ValidateUpdate implements admission.CustomValidator so a webhook will be registered for the type
+func (v *configValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) error {
+	config, ok := newObj.(*Config)
+	if !ok {
+		return fmt.Errorf("expected a Config but got a %T", newObj)
+	}
+	oldConfig, ok := oldObj.(*Config)
+	if !ok {
+		return fmt.Errorf("expected a Config but got a %T", oldObj)
+	}
+	configlog.Info("validate update", "name", config.Name)
+
+	allErrs := config.validateSpec()
+	allErrs = append(allErrs, config.validateSpecUpdate(oldConfig)...)
+	return config.toInvalidError(allErrs)
+}
+
+// ValidateDelete implements admission.CustomValidator so a webhook will be registered for the type
+func (v *configValidator) ValidateDelete(ctx context.Context, obj runtime.Object) error {
+	// no validation is needed for deletion
+	return nil
+}
+
+// validateSpec checks the combinations of settings that can't be expressed in the CRD schema
+func (r *Config) validateSpec() field.ErrorList {
+	var allErrs field.ErrorList
+
+	allErrs = append(allErrs, r.validatePatches()...)
+
+	if r.Spec.Components == nil {
+		return allErrs
+	}
+	componentsPath := field.NewPath("spec", "components")
+
+	if transport := r.Spec.Components.Transport; transport != nil {
+		transportPath := componentsPath.Child("transport")
+		switch transport.Provider {
+		case SyncServiceTransportProvider:
+			if transport.Kafka != nil {
+				allErrs = append(allErrs, field.Forbidden(transportPath.Child("kafka"),
+					"kafka settings must not be set when the transport provider is sync-service"))
+			}
+		case KafkaTransportProvider, "":
+			if transport.SyncService != nil {
+				allErrs = append(allErrs, field.Forbidden(transportPath.Child("syncService"),
+					"sync-service settings must not be set when the transport provider is kafka"))
+			}
+			if transport.Kafka != nil && transport.Kafka.External != nil {
+				externalPath := transportPath.Child("kafka", "external")
+				if authentication := transport.Kafka.External.Authentication; authentication != nil &&
+					authentication.Secret.Name == "" {
+					allErrs = append(allErrs, field.Required(externalPath.Child("authentication", "secret", "name"), ""))
+				}
+			}
+			if transport.Kafka != nil && transport.Kafka.Storage != nil && transport.Kafka.Storage.Size.Sign() <= 0 {
+				allErrs = append(allErrs, field.Invalid(transportPath.Child("kafka", "storage", "size"),
+					transport.Kafka.Storage.Size.String(), "must be greater than zero"))
+			}
+			if transport.Kafka != nil && len(transport.Kafka.Topics) > 0 {
+				allErrs = append(allErrs, transport.Kafka.validateTopics(transportPath.Child("kafka"))...)
+			}
+		}
+	}
+
+	if core := r.Spec.Components.Core; core != nil {
+		corePath := componentsPath.Child("core")
+		if core.Hoh != nil && core.Hoh.SpecTransportBridge != nil && core.Hoh.SpecTransportBridge.MsgSizeLimit == 0 {
+			allErrs = append(allErrs, field.Invalid(
+				corePath.Child("hoh", "specTransportBridge", "msgSizeLimit"), 0, "must be greater than 0"))
+		}
+		if core.LeafHub != nil && core.LeafHub.StatusSync != nil && core.LeafHub.StatusSync.MsgSizeLimit == 0 {
+			allErrs = append(allErrs, field.Invalid(
+				corePath.Child("leafHub", "statusSync", "msgSizeLimit"), 0, "must be greater than 0"))
+		}
+		if core.Hoh != nil && core.Hoh.Nonk8sAPI != nil {
+			nonk8sAPIPath := corePath.Child("hoh", "nonk8sAPI")
+			nonk8sAPI := core.Hoh.Nonk8sAPI
+			switch nonk8sAPI.Exposure {
+			case RouteNonk8sAPIExposure:
+				if nonk8sAPI.TLSSecret != nil || nonk8sAPI.IngressClassName != "" {
+					allErrs = append(allErrs, field.Forbidden(nonk8sAPIPath,
+						"tlsSecret and ingressClassName must not be set when the API is exposed with a route"))
+				}
+			case NoneNonk8sAPIExposure:
+				if nonk8sAPI.Host != "" || nonk8sAPI.TLSSecret != nil || nonk8sAPI.IngressClassName != "" ||
+					len(nonk8sAPI.Annotations) > 0 {
+					allErrs = append(allErrs, field.Forbidden(nonk8sAPIPath,
+						"host, ingressClassName, tlsSecret and annotations must not be set when the API is not exposed"))
+				}
+			}
+		}
+	}
+
+	if database := r.Spec.Components.Database; database != nil && database.Postgresql != nil {
+		postgresqlPath := componentsPath.Child("database", "postgresql")
+		postgresql := database.Postgresql
+		if postgresql.Version != "" && !supportedPostgreSqlVersions.Has(postgresql.Version) {
+			allErrs = append(allErrs, field.NotSupported(postgresqlPath.Child("version"),
+				postgresql.Version, supportedPostgreSqlVersions.List()))
+		}
+		if postgresql.EnableHA && postgresql.Replicas == 1 {
+			allErrs = append(allErrs, field.Invalid(postgresqlPath.Child("replicas"), postgresql.Replicas,
+				"at least 2 replicas are required when HA is enabled"))
+		}
+		if external := postgresql.External; external != nil {
+			externalPath := postgresqlPath.Child("external")
+			if external.ProcessUserSecret.Name == "" {
+				allErrs = append(allErrs, field.Required(externalPath.Child("processUserSecret", "name"), ""))
+			}
+			if external.TransportBridgeUserSecret.Name == "" {
+				allErrs = append(allErrs, field.Required(externalPath.Child("transportBridgeUserSecret", "name"), ""))
+			}
+			if postgresql.EnableHA || postgresql.Replicas != 0 {
+				allErrs = append(allErrs, field.Forbidden(externalPath,
+					"enableHA and replicas must not be set for an external database"))
+			}
+			if postgresql.Storage != nil || postgresql.Resources != nil {
+				allErrs = append(allErrs, field.Forbidden(externalPath,
+					"storage and resources must not be set for an external database"))
+			}
+		}
+		if storage := postgresql.Storage; storage != nil {
+			storagePath := postgresqlPath.Child("storage")
+			if storage.DataSize != nil && storage.DataSize.Sign() <= 0 {
+				allErrs = append(allErrs, field.Invalid(storagePath.Child("dataSize"), storage.DataSize.String(),
+					"must be greater than zero"))
+			}
+			if storage.BackupSize != nil && storage.BackupSize.Sign() <= 0 {
+				allErrs = append(allErrs, field.Invalid(storagePath.Child("backupSize"),
+					storage.BackupSize.String(), "must be greater than zero"))
+			}
+		}
+	}
+
+	return allErrs
+}
+
+// validatePatches checks that the patches can be decoded, the patches that don't apply to the rendered objects
+// are reported in the status
+func (r *Config) validatePatches() field.ErrorList {
+	var allErrs field.ErrorList
+
+	for i, patch := range r.Spec.Patches {
+		patchPath := field.NewPath("spec", "patches").Index(i).Child("patch")
+		patchJSON, err := yaml.YAMLToJSON([]byte(patch.Patch))
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(patchPath, patch.Patch, err.Error()))
+			continue
+		}
+		switch patch.Type {
+		case JSON6902PatchType:
+			if _, err := jsonpatch.DecodePatch(patchJSON); err != nil {
+				allErrs = append(allErrs, field.Invalid(patchPath, patch.Patch, err.Error()))
+			}
+		case StrategicMergePatchType, "":
+			var partialObject map[string]interface{}
+			if err := json.Unmarshal(patchJSON, &partialObject); err != nil {
+				allErrs = append(allErrs, field.Invalid(patchPath, patch.Patch, "must be a partial object"))
+			}
+		}
+	}
+
+	return allErrs
+}
+
+// validateSpecUpdate checks the changes of settings that can't be applied to the deployed components,
+// the volumes of PostgreSQL can be expanded but not shrunk and their storage class is immutable
+func (r *Config) validateSpecUpdate(old *Config) field.ErrorList {
+	var allErrs field.ErrorList
+
+	allErrs = append(allErrs, r.validateKafkaStorageUpdate(old)...)
+	allErrs = append(allErrs, r.validateKafkaTopicsUpdate(old)...)
+
+	oldPostgresql, newPostgresql := old.getPostgreSqlConfig(), r.getPostgreSqlConfig()
+	if oldPostgresql.External != nil || newPostgresql.External != nil {
+		return allErrs
+	}
+
+	storagePath := field.NewPath("spec", "components", "database", "postgresql", "storage")
+	oldStorage, newStorage := oldPostgresql.Storage, newPostgresql.Storage
+	if oldSize, newSize := oldStorage.GetDataSize(), newStorage.GetDataSize(); newSize.Cmp(oldSize) < 0 {
+		allErrs = append(allErrs, field.Invalid(storagePath.Child("dataSize"), newSize.String(),
+			fmt.Sprintf("must not be less than the current size %s", oldSize.String())))
+	}
+	if oldSize, newSize := oldStorage.GetBackupSize(), newStorage.GetBackupSize(); newSize.Cmp(oldSize) < 0 {
+		allErrs = append(allErrs, field.Invalid(storagePath.Child("backupSize"), newSize.String(),
+			fmt.Sprintf("must not be less than the current size %s", oldSize.String())))
+	}
+	if oldStorage.GetStorageClassName() != newStorage.GetStorageClassName() {
+		allErrs = append(allErrs, field.Forbidden(storagePath.Child("storageClassName"),
+			"the storage class of the volumes can't be changed"))
+	}
+
+	return allErrs
+}
+
+// validateKafkaStorageUpdate checks that the storage of the Kafka cluster deployed with Strimzi is only expanded
+func (r *Config) validateKafkaStorageUpdate(old *Config) field.ErrorList {
+	var allErrs field.ErrorList
+
+	oldKafka, newKafka := old.getKafkaConfig(), r.getKafkaConfig()
+	if oldKafka.External != nil || newKafka.External != nil {
+		return allErrs
+	}
+
+	storagePath := field.NewPath("spec", "components", "transport", "kafka", "storage")
+	oldStorage, newStorage := oldKafka.Storage, newKafka.Storage
+	switch {
+	case oldStorage == nil && newStorage == nil:
+	case oldStorage == nil || newStorage == nil:
+		allErrs = append(allErrs, field.Forbidden(storagePath,
+			"the storage of kafka can't be switched between ephemeral and persistent"))
+	default:
+		if newStorage.Size.Cmp(oldStorage.Size) < 0 {
+			allErrs = append(allErrs, field.Invalid(storagePath.Child("size"), newStorage.Size.String(),
+				fmt.Sprintf("must not be less than the current size %s", oldStorage.Size.String())))
+		}
+		if !equality.Semantic.DeepEqual(oldStorage.StorageClassName, newStorage.StorageClassName) {
+			allErrs = append(allErrs, field.Forbidden(storagePath.Child("storageClassName"),
+				"the storage class of the volumes can't be changed"))
+		}
+	}
+
+	return allErrs
+}
+
+// validateTopics checks the declared topics of the Kafka cluster deployed with Strimzi
+func (c *KafkaConfig) validateTopics(kafkaPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	topicsPath := kafkaPath.Child("topics")
+	if c.External != nil {
+		return append(allErrs, field.Forbidden(topicsPath,
+			"topics must not be set for an external kafka cluster, they must be created in the cluster"))
+	}
+
+	brokers := c.Replicas
+	if brokers == 0 {
+		brokers = 3
+	}
+	names := sets.NewString()
+	for i, topic := range c.Topics {
+		if names.Has(topic.Name) {
+			allErrs = append(allErrs, field.Duplicate(topicsPath.Index(i).Child("name"), topic.Name))
+		}
+		names.Insert(topic.Name)
+		if topic.Replicas > brokers {
+			allErrs = append(allErrs, field.Invalid(topicsPath.Index(i).Child("replicas"), topic.Replicas,
+				fmt.Sprintf("must not be greater than the number of brokers %d", brokers)))
+		}
+	}
+
+	return allErrs
+}
+
+// validateKafkaTopicsUpdate checks that the partitions of the topics are not decreased, kafka can't remove
+// the partitions of a topic
+func (r *Config) validateKafkaTopicsUpdate(old *Config) field.ErrorList {
+	var allErrs field.ErrorList
+
+	oldKafka, newKafka := old.getKafkaConfig(), r.getKafkaConfig()
+	if oldKafka.External != nil || newKafka.External != nil {
+		return allErrs
+	}
+
+	oldPartitions := map[string]uint64{}
+	for _, topic := range oldKafka.GetTopics() {
+		oldPartitions[topic.Name] = topic.Partitions
+	}
+	topicsPath := field.NewPath("spec", "components", "transport", "kafka", "topics")
+	for _, topic := range newKafka.GetTopics() {
+		if partitions, found := oldPartitions[topic.Name]; found && topic.Partitions < partitions {
+			allErrs = append(allErrs, field.Invalid(topicsPath.Key(topic.Name).Child("partitions"),
+				topic.Partitions, fmt.Sprintf("must not be less than the current partitions %d", partitions)))
+		}
+	}
+
+	return allErrs
+}
+
+// getKafkaConfig returns the Kafka settings, or empty settings if they are not set
+func (r *Config) getKafkaConfig() *KafkaConfig {
+	if components := r.Spec.Components; components != nil && components.Transport != nil &&
+		components.Transport.Kafka != nil {
+		return components.Transport.Kafka
+	}
+	return &KafkaConfig{}
+}
+
+// getPostgreSqlConfig returns the PostgreSql settings, or empty settings if they are not set
+func (r *Config) getPostgreSqlConfig() *PostgreSqlConfig {
+	if components := r.Spec.Components; components != nil && components.Database != nil &&
+		components.Database.Postgresql != nil {
+		return components.Database.Postgresql
+	}
+	return &PostgreSqlConfig{}
+}
+
+// toInvalidError converts the given validation errors to an Invalid API error
+func (r *Config) toInvalidError(allErrs field.ErrorList) error {
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("Config").GroupKind(), r.Name, allErrs)
+}
@@ -0,0 +1,361 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// errorFields returns the fields of the given validation errors in order
+func errorFields(allErrs field.ErrorList) []string {
+	fields := []string{}
+	for _, err := range allErrs {
+		fields = append(fields, err.Field)
+	}
+	return fields
+}
+
+func quantity(value string) *resource.Quantity {
+	q := resource.MustParse(value)
+	return &q
+}
+
+func transportConfig(transport *TransportConfig) *Config {
+	return &Config{Spec: ConfigSpec{Components: &ComponentsConfig{Transport: transport}}}
+}
+
+func postgresqlConfig(postgresql *PostgreSqlConfig) *Config {
+	return &Config{Spec: ConfigSpec{Components: &ComponentsConfig{
+		Database: &DatabaseConfig{Postgresql: postgresql},
+	}}}
+}
+
+func kafkaConfig(kafka *KafkaConfig) *Config {
+	return transportConfig(&TransportConfig{Provider: KafkaTransportProvider, Kafka: kafka})
+}
+
+func TestValidateSpec(t *testing.T) {
+	gp2 := "gp2"
+
+	tests := []struct {
+		name   string
+		config *Config
+		want   []string
+	}{
+		{
+			name:   "empty spec",
+			config: &Config{},
+			want:   []string{},
+		},
+		{
+			name: "kafka settings with sync-service transport",
+			config: transportConfig(&TransportConfig{
+				Provider: SyncServiceTransportProvider,
+				Kafka:    &KafkaConfig{},
+			}),
+			want: []string{"spec.components.transport.kafka"},
+		},
+		{
+			name: "sync-service settings with kafka transport",
+			config: transportConfig(&TransportConfig{
+				Provider:    KafkaTransportProvider,
+				SyncService: &SyncServiceConfig{},
+			}),
+			want: []string{"spec.components.transport.syncService"},
+		},
+		{
+			name: "external kafka authentication without secret",
+			config: kafkaConfig(&KafkaConfig{External: &ExternalKafkaConfig{
+				BootstrapServers: []string{"kafka:443"},
+				Authentication:   &KafkaAuthenticationConfig{Type: TLSKafkaAuthenticationType},
+			}}),
+			want: []string{"spec.components.transport.kafka.external.authentication.secret.name"},
+		},
+		{
+			name:   "empty kafka storage",
+			config: kafkaConfig(&KafkaConfig{Storage: &KafkaStorageConfig{Size: resource.MustParse("0")}}),
+			want:   []string{"spec.components.transport.kafka.storage.size"},
+		},
+		{
+			name: "topics of an external kafka",
+			config: kafkaConfig(&KafkaConfig{
+				External: &ExternalKafkaConfig{BootstrapServers: []string{"kafka:443"}},
+				Topics:   []KafkaTopicConfig{{Name: "spec"}},
+			}),
+			want: []string{"spec.components.transport.kafka.topics"},
+		},
+		{
+			name: "duplicate topics",
+			config: kafkaConfig(&KafkaConfig{
+				Topics: []KafkaTopicConfig{{Name: "spec"}, {Name: "spec"}},
+			}),
+			want: []string{"spec.components.transport.kafka.topics[1].name"},
+		},
+		{
+			name: "topic replicas greater than the brokers",
+			config: kafkaConfig(&KafkaConfig{
+				Replicas: 2,
+				Topics:   []KafkaTopicConfig{{Name: "spec", Replicas: 2}, {Name: "status", Replicas: 3}},
+			}),
+			want: []string{"spec.components.transport.kafka.topics[1].replicas"},
+		},
+		{
+			name: "topic replicas with the default brokers",
+			config: kafkaConfig(&KafkaConfig{
+				Topics: []KafkaTopicConfig{{Name: "spec", Replicas: 3}},
+			}),
+			want: []string{},
+		},
+		{
+			name: "zero message size limits",
+			config: &Config{Spec: ConfigSpec{Components: &ComponentsConfig{Core: &CoreConfig{
+				Hoh:     &HohConfig{SpecTransportBridge: &SpecTransportBridgeConfig{MsgSizeLimit: 0}},
+				LeafHub: &LeafHubConfig{StatusSync: &LeafHubStatusSyncConfig{MsgSizeLimit: 0}},
+			}}}},
+			want: []string{
+				"spec.components.core.hoh.specTransportBridge.msgSizeLimit",
+				"spec.components.core.leafHub.statusSync.msgSizeLimit",
+			},
+		},
+		{
+			name: "message size limits",
+			config: &Config{Spec: ConfigSpec{Components: &ComponentsConfig{Core: &CoreConfig{
+				Hoh:     &HohConfig{SpecTransportBridge: &SpecTransportBridgeConfig{MsgSizeLimit: 940}},
+				LeafHub: &LeafHubConfig{StatusSync: &LeafHubStatusSyncConfig{MsgSizeLimit: 940}},
+			}}}},
+			want: []string{},
+		},
+		{
+			name:   "unsupported postgres version",
+			config: postgresqlConfig(&PostgreSqlConfig{Version: "12"}),
+			want:   []string{"spec.components.database.postgresql.version"},
+		},
+		{
+			name:   "supported postgres version",
+			config: postgresqlConfig(&PostgreSqlConfig{Version: "13"}),
+			want:   []string{},
+		},
+		{
+			name:   "HA with a single replica",
+			config: postgresqlConfig(&PostgreSqlConfig{EnableHA: true, Replicas: 1}),
+			want:   []string{"spec.components.database.postgresql.replicas"},
+		},
+		{
+			name:   "HA with the default replicas",
+			config: postgresqlConfig(&PostgreSqlConfig{EnableHA: true}),
+			want:   []string{},
+		},
+		{
+			name: "external database without secrets",
+			config: postgresqlConfig(&PostgreSqlConfig{
+				External: &ExternalPostgreSqlConfig{},
+			}),
+			want: []string{
+				"spec.components.database.postgresql.external.processUserSecret.name",
+				"spec.components.database.postgresql.external.transportBridgeUserSecret.name",
+			},
+		},
+		{
+			name: "external database with HA and storage",
+			config: postgresqlConfig(&PostgreSqlConfig{
+				EnableHA: true,
+				Storage:  &PostgreSqlStorageConfig{StorageClassName: &gp2},
+				External: &ExternalPostgreSqlConfig{
+					ProcessUserSecret:         corev1.LocalObjectReference{Name: "process"},
+					TransportBridgeUserSecret: corev1.LocalObjectReference{Name: "transport-bridge"},
+				},
+			}),
+			want: []string{
+				"spec.components.database.postgresql.external",
+				"spec.components.database.postgresql.external",
+			},
+		},
+		{
+			name: "empty postgres volumes",
+			config: postgresqlConfig(&PostgreSqlConfig{Storage: &PostgreSqlStorageConfig{
+				DataSize:   quantity("0"),
+				BackupSize: quantity("0"),
+			}}),
+			want: []string{
+				"spec.components.database.postgresql.storage.dataSize",
+				"spec.components.database.postgresql.storage.backupSize",
+			},
+		},
+		{
+			name: "nonk8s API route with ingress settings",
+			config: &Config{Spec: ConfigSpec{Components: &ComponentsConfig{Core: &CoreConfig{Hoh: &HohConfig{
+				Nonk8sAPI: &Nonk8sAPIConfig{Exposure: RouteNonk8sAPIExposure, IngressClassName: "nginx"},
+			}}}}},
+			want: []string{"spec.components.core.hoh.nonk8sAPI"},
+		},
+		{
+			name: "nonk8s API not exposed with a host",
+			config: &Config{Spec: ConfigSpec{Components: &ComponentsConfig{Core: &CoreConfig{Hoh: &HohConfig{
+				Nonk8sAPI: &Nonk8sAPIConfig{Exposure: NoneNonk8sAPIExposure, Host: "api.example.com"},
+			}}}}},
+			want: []string{"spec.components.core.hoh.nonk8sAPI"},
+		},
+		{
+			name: "valid patches",
+			config: &Config{Spec: ConfigSpec{Patches: []PatchConfig{
+				{
+					Target: PatchTarget{Group: "apps", Kind: "Deployment"},
+					Type:   StrategicMergePatchType,
+					Patch:  "spec:\n  replicas: 2\n",
+				},
+				{
+					Target: PatchTarget{Group: "apps", Kind: "Deployment"},
+					Type:   JSON6902PatchType,
+					Patch:  "- op: replace\n  path: /spec/replicas\n  value: 2\n",
+				},
+			}}},
+			want: []string{},
+		},
+		{
+			name: "patches that can't be decoded",
+			config: &Config{Spec: ConfigSpec{Patches: []PatchConfig{
+				{Target: PatchTarget{Kind: "ConfigMap"}, Patch: "data: [a"},
+				{Target: PatchTarget{Kind: "ConfigMap"}, Type: StrategicMergePatchType, Patch: "- a\n- b\n"},
+				{Target: PatchTarget{Kind: "ConfigMap"}, Type: JSON6902PatchType, Patch: "data:\n  a: b\n"},
+			}}},
+			want: []string{"spec.patches[0].patch", "spec.patches[1].patch", "spec.patches[2].patch"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errorFields(tt.config.validateSpec()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("validateSpec() returned errors for %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateSpecUpdate(t *testing.T) {
+	gp2, gp3 := "gp2", "gp3"
+
+	tests := []struct {
+		name      string
+		oldConfig *Config
+		newConfig *Config
+		want      []string
+	}{
+		{
+			name:      "no changes",
+			oldConfig: &Config{},
+			newConfig: &Config{},
+			want:      []string{},
+		},
+		{
+			name:      "postgres volumes expanded",
+			oldConfig: postgresqlConfig(&PostgreSqlConfig{}),
+			newConfig: postgresqlConfig(&PostgreSqlConfig{Storage: &PostgreSqlStorageConfig{
+				DataSize:   quantity("100Gi"),
+				BackupSize: quantity("100Gi"),
+			}}),
+			want: []string{},
+		},
+		{
+			name: "postgres volumes shrunk",
+			oldConfig: postgresqlConfig(&PostgreSqlConfig{Storage: &PostgreSqlStorageConfig{
+				DataSize: quantity("100Gi"),
+			}}),
+			newConfig: postgresqlConfig(&PostgreSqlConfig{Storage: &PostgreSqlStorageConfig{
+				DataSize:   quantity("60Gi"),
+				BackupSize: quantity("10Gi"),
+			}}),
+			want: []string{
+				"spec.components.database.postgresql.storage.dataSize",
+				"spec.components.database.postgresql.storage.backupSize",
+			},
+		},
+		{
+			name:      "postgres storage class changed",
+			oldConfig: postgresqlConfig(&PostgreSqlConfig{}),
+			newConfig: postgresqlConfig(&PostgreSqlConfig{Storage: &PostgreSqlStorageConfig{
+				StorageClassName: &gp2,
+			}}),
+			want: []string{"spec.components.database.postgresql.storage.storageClassName"},
+		},
+		{
+			name: "switched to an external database",
+			oldConfig: postgresqlConfig(&PostgreSqlConfig{Storage: &PostgreSqlStorageConfig{
+				DataSize: quantity("100Gi"),
+			}}),
+			newConfig: postgresqlConfig(&PostgreSqlConfig{External: &ExternalPostgreSqlConfig{}}),
+			want:      []string{},
+		},
+		{
+			name:      "kafka storage switched from ephemeral to persistent",
+			oldConfig: kafkaConfig(&KafkaConfig{}),
+			newConfig: kafkaConfig(&KafkaConfig{Storage: &KafkaStorageConfig{Size: resource.MustParse("10Gi")}}),
+			want:      []string{"spec.components.transport.kafka.storage"},
+		},
+		{
+			name: "kafka storage expanded",
+			oldConfig: kafkaConfig(&KafkaConfig{Storage: &KafkaStorageConfig{
+				Size: resource.MustParse("10Gi"), StorageClassName: &gp2,
+			}}),
+			newConfig: kafkaConfig(&KafkaConfig{Storage: &KafkaStorageConfig{
+				Size: resource.MustParse("20Gi"), StorageClassName: &gp2,
+			}}),
+			want: []string{},
+		},
+		{
+			name: "kafka storage shrunk and its class changed",
+			oldConfig: kafkaConfig(&KafkaConfig{Storage: &KafkaStorageConfig{
+				Size: resource.MustParse("10Gi"), StorageClassName: &gp2,
+			}}),
+			newConfig: kafkaConfig(&KafkaConfig{Storage: &KafkaStorageConfig{
+				Size: resource.MustParse("5Gi"), StorageClassName: &gp3,
+			}}),
+			want: []string{
+				"spec.components.transport.kafka.storage.size",
+				"spec.components.transport.kafka.storage.storageClassName",
+			},
+		},
+		{
+			name:      "topic partitions increased",
+			oldConfig: kafkaConfig(&KafkaConfig{Topics: []KafkaTopicConfig{{Name: "events", Partitions: 2}}}),
+			newConfig: kafkaConfig(&KafkaConfig{Topics: []KafkaTopicConfig{{Name: "events", Partitions: 4}}}),
+			want:      []string{},
+		},
+		{
+			name:      "topic partitions decreased",
+			oldConfig: kafkaConfig(&KafkaConfig{Topics: []KafkaTopicConfig{{Name: "events", Partitions: 4}}}),
+			newConfig: kafkaConfig(&KafkaConfig{Topics: []KafkaTopicConfig{{Name: "events", Partitions: 2}}}),
+			want:      []string{"spec.components.transport.kafka.topics[events].partitions"},
+		},
+		{
+			name:      "topic removed",
+			oldConfig: kafkaConfig(&KafkaConfig{Topics: []KafkaTopicConfig{{Name: "events", Partitions: 4}}}),
+			newConfig: kafkaConfig(&KafkaConfig{}),
+			want:      []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errorFields(tt.newConfig.validateSpecUpdate(tt.oldConfig)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("validateSpecUpdate() returned errors for %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
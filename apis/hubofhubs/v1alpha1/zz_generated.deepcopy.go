@@ -22,7 +22,9 @@ limitations under the License.
 package v1alpha1
 
 import (
-	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
@@ -61,7 +63,7 @@ func (in *Config) DeepCopyInto(out *Config) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Config.
@@ -127,6 +129,11 @@ func (in *ConfigSpec) DeepCopyInto(out *ConfigSpec) {
 		*out = new(ComponentsConfig)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Patches != nil {
+		in, out := &in.Patches, &out.Patches
+		*out = make([]PatchConfig, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigSpec.
@@ -142,6 +149,27 @@ func (in *ConfigSpec) DeepCopy() *ConfigSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ConfigStatus) DeepCopyInto(out *ConfigStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LeafHubAgents != nil {
+		in, out := &in.LeafHubAgents, &out.LeafHubAgents
+		*out = make([]LeafHubAgentStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.KafkaTopics != nil {
+		in, out := &in.KafkaTopics, &out.KafkaTopics
+		*out = make([]KafkaTopicStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigStatus.
@@ -185,7 +213,7 @@ func (in *DatabaseConfig) DeepCopyInto(out *DatabaseConfig) {
 	if in.Postgresql != nil {
 		in, out := &in.Postgresql, &out.Postgresql
 		*out = new(PostgreSqlConfig)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 }
 
@@ -199,6 +227,58 @@ func (in *DatabaseConfig) DeepCopy() *DatabaseConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExternalKafkaConfig) DeepCopyInto(out *ExternalKafkaConfig) {
+	*out = *in
+	if in.BootstrapServers != nil {
+		in, out := &in.BootstrapServers, &out.BootstrapServers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.CASecret != nil {
+		in, out := &in.CASecret, &out.CASecret
+		*out = new(v1.LocalObjectReference)
+		**out = **in
+	}
+	if in.Authentication != nil {
+		in, out := &in.Authentication, &out.Authentication
+		*out = new(KafkaAuthenticationConfig)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExternalKafkaConfig.
+func (in *ExternalKafkaConfig) DeepCopy() *ExternalKafkaConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ExternalKafkaConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExternalPostgreSqlConfig) DeepCopyInto(out *ExternalPostgreSqlConfig) {
+	*out = *in
+	out.ProcessUserSecret = in.ProcessUserSecret
+	out.TransportBridgeUserSecret = in.TransportBridgeUserSecret
+	if in.AdminSecret != nil {
+		in, out := &in.AdminSecret, &out.AdminSecret
+		*out = new(v1.LocalObjectReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExternalPostgreSqlConfig.
+func (in *ExternalPostgreSqlConfig) DeepCopy() *ExternalPostgreSqlConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ExternalPostgreSqlConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GlobalConfig) DeepCopyInto(out *GlobalConfig) {
 	*out = *in
@@ -207,6 +287,16 @@ func (in *GlobalConfig) DeepCopyInto(out *GlobalConfig) {
 		*out = new(HeartbeatIntervalConfig)
 		**out = **in
 	}
+	if in.ImagePullSecrets != nil {
+		in, out := &in.ImagePullSecrets, &out.ImagePullSecrets
+		*out = make([]v1.LocalObjectReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.ImageOverrides != nil {
+		in, out := &in.ImageOverrides, &out.ImageOverrides
+		*out = new(ImageOverridesConfig)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GlobalConfig.
@@ -240,7 +330,7 @@ func (in *HohConfig) DeepCopyInto(out *HohConfig) {
 	if in.Nonk8sAPI != nil {
 		in, out := &in.Nonk8sAPI, &out.Nonk8sAPI
 		*out = new(Nonk8sAPIConfig)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	if in.RBAC != nil {
 		in, out := &in.RBAC, &out.RBAC
@@ -279,9 +369,62 @@ func (in *HohConfig) DeepCopy() *HohConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageOverridesConfig) DeepCopyInto(out *ImageOverridesConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageOverridesConfig.
+func (in *ImageOverridesConfig) DeepCopy() *ImageOverridesConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ImageOverridesConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KafkaAuthenticationConfig) DeepCopyInto(out *KafkaAuthenticationConfig) {
+	*out = *in
+	out.Secret = in.Secret
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KafkaAuthenticationConfig.
+func (in *KafkaAuthenticationConfig) DeepCopy() *KafkaAuthenticationConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(KafkaAuthenticationConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *KafkaConfig) DeepCopyInto(out *KafkaConfig) {
 	*out = *in
+	if in.Storage != nil {
+		in, out := &in.Storage, &out.Storage
+		*out = new(KafkaStorageConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = new(v1.ResourceRequirements)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Topics != nil {
+		in, out := &in.Topics, &out.Topics
+		*out = make([]KafkaTopicConfig, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.External != nil {
+		in, out := &in.External, &out.External
+		*out = new(ExternalKafkaConfig)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KafkaConfig.
@@ -294,6 +437,91 @@ func (in *KafkaConfig) DeepCopy() *KafkaConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KafkaStorageConfig) DeepCopyInto(out *KafkaStorageConfig) {
+	*out = *in
+	out.Size = in.Size.DeepCopy()
+	if in.StorageClassName != nil {
+		in, out := &in.StorageClassName, &out.StorageClassName
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KafkaStorageConfig.
+func (in *KafkaStorageConfig) DeepCopy() *KafkaStorageConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(KafkaStorageConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KafkaTopicConfig) DeepCopyInto(out *KafkaTopicConfig) {
+	*out = *in
+	if in.RetentionMs != nil {
+		in, out := &in.RetentionMs, &out.RetentionMs
+		*out = new(int64)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KafkaTopicConfig.
+func (in *KafkaTopicConfig) DeepCopy() *KafkaTopicConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(KafkaTopicConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KafkaTopicStatus) DeepCopyInto(out *KafkaTopicStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KafkaTopicStatus.
+func (in *KafkaTopicStatus) DeepCopy() *KafkaTopicStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KafkaTopicStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LeafHubAgentStatus) DeepCopyInto(out *LeafHubAgentStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LeafHubAgentStatus.
+func (in *LeafHubAgentStatus) DeepCopy() *LeafHubAgentStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(LeafHubAgentStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *LeafHubConfig) DeepCopyInto(out *LeafHubConfig) {
 	*out = *in
@@ -372,6 +600,18 @@ func (in *LeafHubStatusSyncIntervalSettings) DeepCopy() *LeafHubStatusSyncInterv
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Nonk8sAPIConfig) DeepCopyInto(out *Nonk8sAPIConfig) {
 	*out = *in
+	if in.TLSSecret != nil {
+		in, out := &in.TLSSecret, &out.TLSSecret
+		*out = new(v1.LocalObjectReference)
+		**out = **in
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Nonk8sAPIConfig.
@@ -384,9 +624,55 @@ func (in *Nonk8sAPIConfig) DeepCopy() *Nonk8sAPIConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PatchConfig) DeepCopyInto(out *PatchConfig) {
+	*out = *in
+	out.Target = in.Target
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PatchConfig.
+func (in *PatchConfig) DeepCopy() *PatchConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(PatchConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PatchTarget) DeepCopyInto(out *PatchTarget) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PatchTarget.
+func (in *PatchTarget) DeepCopy() *PatchTarget {
+	if in == nil {
+		return nil
+	}
+	out := new(PatchTarget)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PostgreSqlConfig) DeepCopyInto(out *PostgreSqlConfig) {
 	*out = *in
+	if in.Storage != nil {
+		in, out := &in.Storage, &out.Storage
+		*out = new(PostgreSqlStorageConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = new(v1.ResourceRequirements)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.External != nil {
+		in, out := &in.External, &out.External
+		*out = new(ExternalPostgreSqlConfig)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgreSqlConfig.
@@ -399,6 +685,36 @@ func (in *PostgreSqlConfig) DeepCopy() *PostgreSqlConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgreSqlStorageConfig) DeepCopyInto(out *PostgreSqlStorageConfig) {
+	*out = *in
+	if in.DataSize != nil {
+		in, out := &in.DataSize, &out.DataSize
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.BackupSize != nil {
+		in, out := &in.BackupSize, &out.BackupSize
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.StorageClassName != nil {
+		in, out := &in.StorageClassName, &out.StorageClassName
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgreSqlStorageConfig.
+func (in *PostgreSqlStorageConfig) DeepCopy() *PostgreSqlStorageConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgreSqlStorageConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RBACConfig) DeepCopyInto(out *RBACConfig) {
 	*out = *in
@@ -495,7 +811,7 @@ func (in *TransportConfig) DeepCopyInto(out *TransportConfig) {
 	if in.Kafka != nil {
 		in, out := &in.Kafka, &out.Kafka
 		*out = new(KafkaConfig)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	if in.SyncService != nil {
 		in, out := &in.SyncService, &out.SyncService
@@ -18,13 +18,16 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"os"
+	"strings"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -32,6 +35,8 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	cdpov1beta1 "github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	workv1 "open-cluster-management.io/api/work/v1"
 
 	hubofhubsv1alpha1 "github.com/stolostron/hub-of-hubs-operator/apis/hubofhubs/v1alpha1"
 	hubofhubscontrollers "github.com/stolostron/hub-of-hubs-operator/pkg/controllers/hubofhubs"
@@ -46,6 +51,8 @@ var (
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 	utilruntime.Must(cdpov1beta1.AddToScheme(scheme))
+	utilruntime.Must(clusterv1.AddToScheme(scheme))
+	utilruntime.Must(workv1.AddToScheme(scheme))
 	utilruntime.Must(hubofhubsv1alpha1.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
 }
@@ -54,11 +61,23 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var serverSideApply bool
+	var manifestOverridesDir string
+	var manifestOverridesConfigMap string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.BoolVar(&serverSideApply, "server-side-apply", false,
+		"Deploy the hub-of-hubs components with server-side apply. "+
+			"Conflicts with the fields of other managers are reported as errors instead of being overwritten.")
+	flag.StringVar(&manifestOverridesDir, "manifest-overrides-dir", "",
+		"A directory with templates that replace or add to the embedded manifests, "+
+			"with the same layout as the embedded manifests, e.g. manifests/manager/deployment.yaml.")
+	flag.StringVar(&manifestOverridesConfigMap, "manifest-overrides-configmap", "",
+		"The namespace/name of a ConfigMap with templates that replace or add to the embedded manifests, "+
+			"the keys are the paths of the templates with __ for /, e.g. manifests__manager__deployment.yaml.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -67,6 +86,17 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	var overridesConfigMap types.NamespacedName
+	if manifestOverridesConfigMap != "" {
+		namespacedName := strings.SplitN(manifestOverridesConfigMap, "/", 2)
+		if len(namespacedName) != 2 || namespacedName[0] == "" || namespacedName[1] == "" {
+			setupLog.Error(fmt.Errorf("expected namespace/name, got %q", manifestOverridesConfigMap),
+				"invalid manifest overrides ConfigMap")
+			os.Exit(1)
+		}
+		overridesConfigMap = types.NamespacedName{Namespace: namespacedName[0], Name: namespacedName[1]}
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
 		MetricsBindAddress:     metricsAddr,
@@ -81,12 +111,21 @@ func main() {
 	}
 
 	if err = (&hubofhubscontrollers.ConfigReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:                     mgr.GetClient(),
+		Scheme:                     mgr.GetScheme(),
+		ServerSideApply:            serverSideApply,
+		ManifestOverridesDir:       manifestOverridesDir,
+		ManifestOverridesConfigMap: overridesConfigMap,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Config")
 		os.Exit(1)
 	}
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err = (&hubofhubsv1alpha1.Config{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Config")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
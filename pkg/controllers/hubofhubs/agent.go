@@ -0,0 +1,201 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hubofhubs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	workv1 "open-cluster-management.io/api/work/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	hubofhubsv1alpha1 "github.com/stolostron/hub-of-hubs-operator/apis/hubofhubs/v1alpha1"
+	"github.com/stolostron/hub-of-hubs-operator/pkg/renderer"
+)
+
+const (
+	agentManifestWorkName = "hub-of-hubs-agent"
+	agentNamespace        = "open-cluster-management"
+)
+
+// agentConfig contains the values rendered into the agent manifests of a leaf hub
+type agentConfig struct {
+	Images           images
+	ImagePullSecrets []corev1.LocalObjectReference
+	LeafHubID        string
+	TransportType    string
+	Kafka            kafkaConnection
+	Settings         agentSettings
+	SyncService      essSettings
+	// ESSUsername and ESSPassword are the credentials of the ESS of the leaf hub to authenticate to the CSS
+	ESSUsername []byte
+	ESSPassword []byte
+	// ESSSecretsHash is the hash of the secret read by the ESS
+	ESSSecretsHash string
+}
+
+// reconcileLeafHubAgents deploys the hub-of-hubs agent to each leaf hub with a ManifestWork,
+// removes the agent from the managed clusters that are no longer leaf hubs and records the apply status
+// of every leaf hub in the Config status
+func (r *ConfigReconciler) reconcileLeafHubAgents(ctx context.Context, hohConfig *hubofhubsv1alpha1.Config,
+	hohRenderer renderer.Renderer,
+) error {
+	log := ctrllog.FromContext(ctx)
+
+	leafHubs := &clusterv1.ManagedClusterList{}
+	if err := r.List(ctx, leafHubs, client.MatchingLabels{hubofhubsv1alpha1.LeafHubLabelKey: "true"}); err != nil {
+		return err
+	}
+
+	transportType := getTransportType(hohConfig)
+	values := agentConfig{
+		Images:           getImages(hohConfig),
+		ImagePullSecrets: getImagePullSecrets(hohConfig),
+		TransportType:    transportType,
+		Settings:         getAgentSettings(hohConfig),
+	}
+
+	// the sync-service ESS manifests are only deployed for sync-service transport
+	filter := "agent-"
+	if len(leafHubs.Items) > 0 {
+		var err error
+		if transportType == string(hubofhubsv1alpha1.SyncServiceTransportProvider) {
+			filter = ""
+			var ess *essSettings
+			if ess, err = r.getESSSettings(ctx, hohConfig); err == nil {
+				values.SyncService = *ess
+			}
+		} else {
+			var connection *kafkaConnection
+			if connection, err = r.getKafkaConnection(ctx, hohConfig); err == nil {
+				values.Kafka = *connection
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// the leaf hubs have no image pull secrets, they are deployed with the agent
+	pullSecretNamespaces := []string{agentNamespace}
+	if filter == "" {
+		pullSecretNamespaces = append(pullSecretNamespaces, syncServiceNamespace)
+	}
+	var pullSecrets []runtime.Object
+	if len(leafHubs.Items) > 0 {
+		var err error
+		if pullSecrets, err = r.getImagePullSecretCopies(ctx, hohConfig, pullSecretNamespaces...); err != nil {
+			return err
+		}
+	}
+
+	var errs []error
+	var agentStatuses []hubofhubsv1alpha1.LeafHubAgentStatus
+	leafHubNames := map[string]bool{}
+	for _, leafHub := range leafHubs.Items {
+		leafHubNames[leafHub.Name] = true
+
+		agentObjects, err := hohRenderer.RenderForClusterWithFilter(leafHub.Name, "manifests/agent", filter,
+			func(cluster, component string) (interface{}, error) {
+				clusterValues := values
+				clusterValues.LeafHubID = cluster
+				if filter == "" {
+					clusterValues.ESSUsername = []byte(cluster)
+					clusterValues.ESSPassword = []byte(values.SyncService.password(cluster))
+					clusterValues.ESSSecretsHash = hashSecrets(values.SyncService.CACert, clusterValues.ESSPassword)
+				}
+				return clusterValues, nil
+			})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to render the agent for leaf hub %s: %w", leafHub.Name, err))
+			continue
+		}
+
+		log.Info("Creating or updating agent ManifestWork", "leafHub", leafHub.Name)
+		work, err := r.applyAgentManifestWork(ctx, leafHub.Name, addImagePullSecrets(agentObjects, pullSecrets))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to apply the agent to leaf hub %s: %w", leafHub.Name, err))
+			continue
+		}
+
+		agentStatuses = append(agentStatuses, hubofhubsv1alpha1.LeafHubAgentStatus{
+			Name:       leafHub.Name,
+			Conditions: work.Status.Conditions,
+		})
+	}
+	hohConfig.Status.LeafHubAgents = agentStatuses
+
+	// remove the agent from the managed clusters that are no longer leaf hubs
+	works := &workv1.ManifestWorkList{}
+	if err := r.List(ctx, works, client.MatchingLabels{hubofhubsv1alpha1.LeafHubLabelKey: "true"}); err != nil {
+		return err
+	}
+	for i := range works.Items {
+		work := &works.Items[i]
+		if work.Name != agentManifestWorkName || leafHubNames[work.Namespace] {
+			continue
+		}
+		log.Info("Deleting agent ManifestWork", "leafHub", work.Namespace)
+		if err := r.Delete(ctx, work); err != nil && !errors.IsNotFound(err) {
+			errs = append(errs, err)
+		}
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// applyAgentManifestWork creates or updates the ManifestWork that deploys the given agent objects to the leaf hub
+func (r *ConfigReconciler) applyAgentManifestWork(ctx context.Context, leafHub string,
+	agentObjects []runtime.Object,
+) (*workv1.ManifestWork, error) {
+	manifests := make([]workv1.Manifest, 0, len(agentObjects))
+	for _, obj := range agentObjects {
+		raw, err := json.Marshal(obj)
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, workv1.Manifest{RawExtension: runtime.RawExtension{Raw: raw}})
+	}
+
+	work := &workv1.ManifestWork{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      agentManifestWorkName,
+			Namespace: leafHub,
+		},
+	}
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, work, func() error {
+		labels := work.GetLabels()
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels[hubofhubsv1alpha1.LeafHubLabelKey] = "true"
+		work.SetLabels(labels)
+		work.Spec.Workload.Manifests = manifests
+		return nil
+	})
+
+	return work, err
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hubofhubs
+
+import (
+	"testing"
+
+	hubofhubsv1alpha1 "github.com/stolostron/hub-of-hubs-operator/apis/hubofhubs/v1alpha1"
+	"github.com/stolostron/hub-of-hubs-operator/pkg/renderer"
+)
+
+func TestRenderAgentArgs(t *testing.T) {
+	hohConfig := newTestConfig()
+	kafka := kafkaConnection{BootstrapServer: "kafka.example.com:443", CA: "Y2E="}
+
+	tests := []struct {
+		name      string
+		filter    string
+		values    agentConfig
+		wantArgs  []string
+		wantKafka bool
+	}{
+		{
+			name:   "kafka",
+			filter: "agent-",
+			values: agentConfig{
+				TransportType: string(hubofhubsv1alpha1.KafkaTransportProvider),
+				Kafka:         kafka,
+				Settings:      getAgentSettings(hohConfig),
+			},
+			wantArgs: []string{
+				"--leaf-hub-name=hub1",
+				"--transport-type=kafka",
+				"--kafka-bootstrap-server=kafka.example.com:443",
+				"--kafka-ssl-ca=Y2E=",
+				"--kafka-message-size-limit=940",
+				"--consumer-worker-pool-size=10",
+				"--status-delta-count-switch-factor=100",
+				"--transport-message-compression-type=gzip",
+				"--heartbeat-interval=1m0s",
+			},
+			wantKafka: true,
+		},
+		{
+			name: "sync-service",
+			values: agentConfig{
+				TransportType: string(hubofhubsv1alpha1.SyncServiceTransportProvider),
+				Settings:      getAgentSettings(hohConfig),
+			},
+			wantArgs: []string{
+				"--leaf-hub-name=hub1",
+				"--transport-type=sync-service",
+				"--consumer-worker-pool-size=10",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects, err := renderer.NewHoHRenderer(fs).RenderForClusterWithFilter("hub1", "manifests/agent",
+				tt.filter, func(cluster, component string) (interface{}, error) {
+					values := tt.values
+					values.LeafHubID = cluster
+					return values, nil
+				})
+			if err != nil {
+				t.Fatalf("failed to render: %v", err)
+			}
+			checkTransportArgs(t, objects, "hub-of-hubs-agent", "hub-of-hubs-agent-kafka-credentials", tt.wantArgs,
+				tt.wantKafka)
+		})
+	}
+}
@@ -18,13 +18,36 @@ package hubofhubs
 
 import (
 	"context"
+	"crypto/sha256"
 	"embed"
+	"encoding/hex"
+	"fmt"
+	iofs "io/fs"
+	"os"
+	"strings"
+	"testing/fstest"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	workv1 "open-cluster-management.io/api/work/v1"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	hubofhubsv1alpha1 "github.com/stolostron/hub-of-hubs-operator/apis/hubofhubs/v1alpha1"
 	"github.com/stolostron/hub-of-hubs-operator/pkg/deployer"
@@ -34,26 +57,96 @@ import (
 //go:embed manifests
 //go:embed manifests/agent
 //go:embed manifests/database
+//go:embed manifests/database-init
+//go:embed manifests/database-secrets
+//go:embed manifests/hub-config
 //go:embed manifests/manager
 //go:embed manifests/transport/kafka
 //go:embed manifests/transport/sync-service
 var fs embed.FS
 
+// databaseConfig contains the values rendered into the database manifests
+type databaseConfig struct {
+	Images           images
+	ImagePullSecrets []corev1.LocalObjectReference
+	// JobNamespace is the namespace of the database initialization job
+	JobNamespace string
+	// AdminSecret is the connection secret of the user that initializes the database
+	AdminSecret                string
+	ProcessDatabaseURL         []byte
+	TransportBridgeDatabaseURL []byte
+	// EnableHA enables the automatic failover of the PostgresCluster
+	EnableHA          bool
+	PostgresReplicas  uint64
+	PgBouncerReplicas uint64
+	DataVolumeSize    string
+	BackupVolumeSize  string
+	StorageClassName  string
+	// ResourceRequests and ResourceLimits are the compute resources of the PostgreSQL instances
+	ResourceRequests map[string]string
+	ResourceLimits   map[string]string
+}
+
+// transportConfig contains the values rendered into the transport manifests
+type transportConfig struct {
+	Images           images
+	ImagePullSecrets []corev1.LocalObjectReference
+	Kafka            kafkaClusterConfig
+	SyncService      syncServiceSettings
+}
+
+// hubConfigValues contains the values rendered into the hub-of-hubs Config read by the manager
+type hubConfigValues struct {
+	AggregationLevel    string
+	HeartbeatIntervals  hubofhubsv1alpha1.HeartbeatIntervalConfig
+	EnableLocalPolicies bool
+}
+
+// managerConfig contains the values rendered into the manager manifests
 type managerConfig struct {
-	Registry      string
-	ImageTag      string
-	TransportType string
+	Images           images
+	ImagePullSecrets []corev1.LocalObjectReference
+	TransportType    string
+	Kafka            kafkaConnection
+	Settings         managerSettings
+	API              nonk8sAPISettings
+	// SyncServicePollingInterval is the interval in seconds of the manager polling the sync-service CSS
+	SyncServicePollingInterval uint64
+	// SecretsHash is the hash of the secrets read by the manager
+	SecretsHash string
 }
 
 // ConfigReconciler reconciles a Config object
 type ConfigReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+	// ServerSideApply selects the server-side apply deployer instead of the get-and-update one
+	ServerSideApply bool
+	// ManifestOverridesDir is a directory with templates that replace or add to the embedded manifests,
+	// it has the same layout as the embedded manifests, e.g. manifests/manager/deployment.yaml
+	ManifestOverridesDir string
+	// ManifestOverridesConfigMap is a ConfigMap with templates that replace or add to the embedded manifests
+	// and the templates of the directory, the keys are the paths of the templates with "__" for "/",
+	// e.g. manifests__manager__deployment.yaml
+	ManifestOverridesConfigMap types.NamespacedName
 }
 
 //+kubebuilder:rbac:groups=hubofhubs.open-cluster-management.io,resources=configs,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=hubofhubs.open-cluster-management.io,resources=configs/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=hubofhubs.open-cluster-management.io,resources=configs/finalizers,verbs=update
+//+kubebuilder:rbac:groups=cluster.open-cluster-management.io,resources=managedclusters,verbs=get;list;watch
+//+kubebuilder:rbac:groups=work.open-cluster-management.io,resources=manifestworks,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=namespaces;serviceaccounts;configmaps;secrets;services,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;watch
+//+kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles;rolebindings;clusterroles;clusterrolebindings,verbs=get;list;watch;create;update;patch;delete;escalate;bind
+//+kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=postgres-operator.crunchydata.com,resources=postgresclusters,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=kafka.strimzi.io,resources=kafkas;kafkatopics,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=hub-of-hubs.open-cluster-management.io,resources=configs,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=route.openshift.io,resources=routes,verbs=get;list;watch;create;update;patch;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -73,7 +166,7 @@ func (r *ConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 	if err != nil {
 		if errors.IsNotFound(err) {
 			// Request object not found, could have been deleted after reconcile request.
-			// Owned objects are automatically garbage collected. For additional cleanup logic use finalizers.
+			// The deployed objects are removed by the finalizer before the Config is gone.
 			// Return and don't requeue
 			log.Info("Config resource not found. Ignoring since object must be deleted")
 			return ctrl.Result{}, nil
@@ -83,106 +176,579 @@ func (r *ConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		return ctrl.Result{}, err
 	}
 
-	transportType := "kafka"
-	if hohConfig.Spec.Components != nil && hohConfig.Spec.Components.Transport != nil {
-		if hohConfig.Spec.Components.Transport.Provider == hubofhubsv1alpha1.SyncServiceTransportProvider {
-			transportType = "sync-service"
+	if hohConfig.GetDeletionTimestamp() != nil {
+		return r.reconcileDeletion(ctx, hohConfig)
+	}
+
+	// the finalizer is added before anything is deployed, so that every deployed object is removed on deletion
+	if !controllerutil.ContainsFinalizer(hohConfig, configFinalizer) {
+		controllerutil.AddFinalizer(hohConfig, configFinalizer)
+		if err := r.Update(ctx, hohConfig); err != nil {
+			log.Error(err, "Failed to add the finalizer to Config")
+			return ctrl.Result{}, err
 		}
 	}
 
-	// create new HoHRenderer and HoHDeployer
-	hohRenderer := renderer.NewHoHRenderer(fs)
-	hohDeployer := deployer.NewHoHDeployer(r.Client)
+	original := hohConfig.DeepCopy()
 
-	dbObjects, err := hohRenderer.Render("manifests/database", func(component string) (interface{}, error) {
-		dbConfig := struct {
-			Registry string
-			ImageTag string
-		}{
-			Registry: "quay.io/open-cluster-management-hub-of-hubs",
-			ImageTag: "latest",
+	waiting, reconcileErr := r.reconcileHubComponents(ctx, hohConfig)
+	if err := r.updateStatus(ctx, original, hohConfig); err != nil {
+		log.Error(err, "Failed to update Config status")
+		if reconcileErr == nil {
+			return ctrl.Result{}, err
 		}
+	}
+	if reconcileErr == nil && waiting {
+		// the status changes of the deployed objects don't trigger a reconciliation, check them again later
+		return ctrl.Result{RequeueAfter: readinessRequeuePeriod}, nil
+	}
 
-		return dbConfig, err
-	})
+	return ctrl.Result{}, reconcileErr
+}
+
+// hubComponent is a hub-of-hubs component deployed in the hub-of-hubs cluster
+type hubComponent struct {
+	name          string
+	conditionType string
+	// dependsOn are the names of the components that must be ready before this component is deployed
+	dependsOn []string
+	// render returns the objects of the component in the order they are deployed
+	render func(context.Context, *hubofhubsv1alpha1.Config, renderer.Renderer) ([]runtime.Object, error)
+	// ready checks that the deployed component is ready to be used by the components depending on it
+	ready readinessCheck
+}
+
+// hubComponents returns the dependency graph of the components deployed in the hub-of-hubs cluster,
+// every component is declared after the components it depends on
+func (r *ConfigReconciler) hubComponents() []hubComponent {
+	return []hubComponent{
+		{
+			name:          "database",
+			conditionType: hubofhubsv1alpha1.ConditionTypeDatabase,
+			render:        r.renderDatabase,
+			ready:         r.databaseReady,
+		},
+		{
+			name:          "database-init",
+			conditionType: hubofhubsv1alpha1.ConditionTypeDatabaseInitialized,
+			dependsOn:     []string{"database"},
+			render:        r.renderDatabaseInit,
+			ready:         r.databaseInitialized,
+		},
+		{
+			name:          "transport",
+			conditionType: hubofhubsv1alpha1.ConditionTypeTransport,
+			render:        r.renderTransport,
+			ready:         r.transportReady,
+		},
+		{
+			name:          "hub-config",
+			conditionType: hubofhubsv1alpha1.ConditionTypeHubConfig,
+			render:        r.renderHubConfig,
+			ready:         r.hubConfigReady,
+		},
+		{
+			name:          "manager",
+			conditionType: hubofhubsv1alpha1.ConditionTypeManager,
+			dependsOn:     []string{"database-init", "transport", "hub-config"},
+			render:        r.renderManager,
+			ready:         r.managerReady,
+		},
+	}
+}
+
+// agentsDependOn are the names of the components that must be ready before the agents are deployed
+// to the leaf hubs, the agents need the address of the transport
+var agentsDependOn = []string{"transport"}
+
+// reconcileHubComponents deploys the hub-of-hubs components following their dependencies and records
+// the result of each of them as a condition of the Config status. A component is held back until
+// the components it depends on are ready, it returns true if a component is waiting for them.
+func (r *ConfigReconciler) reconcileHubComponents(ctx context.Context, hohConfig *hubofhubsv1alpha1.Config,
+) (bool, error) {
+	log := ctrllog.FromContext(ctx)
+
+	// create new HoHRenderer and HoHDeployer
+	hohRenderer, err := r.newRenderer(ctx)
 	if err != nil {
-		return ctrl.Result{}, err
+		return false, err
+	}
+	hohDeployer := r.newDeployer()
+
+	inv, err := r.getInventory(ctx, hohConfig)
+	if err != nil {
+		return false, err
+	}
+
+	// all the components are rendered first, so that an object moved to another component isn't pruned
+	components := r.hubComponents()
+	renderedObjects := map[string][]runtime.Object{}
+	renderErrs := map[string]error{}
+	var renderedRefs []objectRef
+	patches := newPatchResults(hohConfig)
+	for _, component := range components {
+		objects, err := component.render(ctx, hohConfig, hohRenderer)
+		if err == nil {
+			objects, err = r.patchObjects(hohConfig, objects, patches)
+		}
+		if err != nil {
+			renderErrs[component.name] = err
+			continue
+		}
+		refs, err := newObjectRefs(objects)
+		if err != nil {
+			renderErrs[component.name] = err
+			continue
+		}
+		renderedObjects[component.name] = objects
+		renderedRefs = append(renderedRefs, refs...)
 	}
+	setPatchesCondition(hohConfig, patches)
+
+	readyComponents := map[string]bool{}
+	waiting := false
+	var errs []error
+	for _, component := range components {
+		if dependency := firstNotReady(component.dependsOn, readyComponents); dependency != "" {
+			setCondition(hohConfig, component.conditionType, metav1.ConditionUnknown, reasonWaitingForDeps,
+				fmt.Sprintf("waiting for the %s component to be ready", dependency))
+			waiting = true
+			continue
+		}
 
-	var dbInitJobObj runtime.Object
-	for _, obj := range dbObjects {
-		if obj.GetObjectKind().GroupVersionKind().Kind == "Job" {
-			dbInitJobObj = obj
+		if err := renderErrs[component.name]; err != nil {
+			// a template that can't be rendered with the Config is reported instead of deploying partial objects
+			setCondition(hohConfig, component.conditionType, metav1.ConditionFalse, reasonRenderFailed,
+				fmt.Sprintf("failed to render the %s component: %v", component.name, err))
+			errs = append(errs, err)
 			continue
 		}
 
-		log.Info("Creating or updating object", "object", obj)
-		err := hohDeployer.Deploy(obj)
+		objects := renderedObjects[component.name]
+		err := r.deployObjects(ctx, hohConfig, hohDeployer, objects)
+		if meta.IsNoMatchError(err) {
+			// the CRD of a custom resource is not installed, or is deployed with it and not established yet
+			setCondition(hohConfig, component.conditionType, metav1.ConditionUnknown, reasonNotReady,
+				fmt.Sprintf("waiting for the CRD of the %s component: %v", component.name, err))
+			waiting = true
+			continue
+		}
 		if err != nil {
-			return ctrl.Result{}, err
+			if recordErr := r.recordFailedComponent(ctx, hohConfig, inv, component.name,
+				objects); recordErr != nil {
+				log.Error(recordErr, "Failed to record the inventory", "component", component.name)
+			}
+		} else {
+			// the objects that are no longer rendered are pruned once the rendered ones are deployed
+			err = r.recordComponent(ctx, hohConfig, hohDeployer, inv, component.name, objects, renderedRefs)
+		}
+		if err != nil {
+			setCondition(hohConfig, component.conditionType, metav1.ConditionFalse, reasonDeployFailed,
+				fmt.Sprintf("failed to deploy the %s component: %v", component.name, err))
+			errs = append(errs, err)
+			continue
+		}
+
+		ready, message, err := component.ready(ctx, hohConfig)
+		if errors.IsNotFound(err) {
+			// the objects that were just created may not be in the cache yet
+			ready, message, err = false, err.Error(), nil
 		}
+		if err != nil {
+			setCondition(hohConfig, component.conditionType, metav1.ConditionFalse, reasonNotReady,
+				fmt.Sprintf("the %s component is not ready: %v", component.name, err))
+			errs = append(errs, err)
+			continue
+		}
+		if !ready {
+			setCondition(hohConfig, component.conditionType, metav1.ConditionUnknown, reasonNotReady, message)
+			waiting = true
+			continue
+		}
+
+		readyComponents[component.name] = true
+		setCondition(hohConfig, component.conditionType, metav1.ConditionTrue, reasonReady,
+			fmt.Sprintf("the %s component is ready", component.name))
 	}
 
-	// create or updating the database initialization job
-	log.Info("Creating or updating object", "object", dbInitJobObj)
-	if err = hohDeployer.Deploy(dbInitJobObj); err != nil {
-		return ctrl.Result{}, err
+	if dependency := firstNotReady(agentsDependOn, readyComponents); dependency != "" {
+		setCondition(hohConfig, hubofhubsv1alpha1.ConditionTypeLeafHubAgents, metav1.ConditionUnknown,
+			reasonWaitingForDeps, fmt.Sprintf("waiting for the %s component to be ready", dependency))
+		return true, utilerrors.NewAggregate(errs)
+	}
+	if err := r.reconcileLeafHubAgents(ctx, hohConfig, hohRenderer); err != nil {
+		setCondition(hohConfig, hubofhubsv1alpha1.ConditionTypeLeafHubAgents, metav1.ConditionFalse,
+			reasonDeployFailed, fmt.Sprintf("failed to deploy the leaf hub agents component: %v", err))
+		errs = append(errs, err)
+	} else {
+		setCondition(hohConfig, hubofhubsv1alpha1.ConditionTypeLeafHubAgents, metav1.ConditionTrue,
+			reasonDeployed, "the leaf hub agents component is deployed")
+	}
+
+	return waiting, utilerrors.NewAggregate(errs)
+}
+
+// firstNotReady returns the first of the given components that is not ready, or an empty string
+func firstNotReady(components []string, readyComponents map[string]bool) string {
+	for _, component := range components {
+		if !readyComponents[component] {
+			return component
+		}
 	}
+	return ""
+}
 
-	transportObjects, err := hohRenderer.Render("manifests/transport/"+transportType, func(component string) (interface{}, error) {
-		dbConfig := struct {
-			Registry string
-			ImageTag string
-		}{
-			Registry: "quay.io/open-cluster-management-hub-of-hubs",
-			ImageTag: "latest",
+// renderTransport renders the kafka cluster or the sync-service CSS, nothing is deployed for an external kafka
+func (r *ConfigReconciler) renderTransport(ctx context.Context, hohConfig *hubofhubsv1alpha1.Config,
+	hohRenderer renderer.Renderer,
+) ([]runtime.Object, error) {
+	values := transportConfig{
+		Images:           getImages(hohConfig),
+		ImagePullSecrets: getImagePullSecrets(hohConfig),
+	}
+	if getTransportType(hohConfig) == string(hubofhubsv1alpha1.SyncServiceTransportProvider) {
+		syncService, err := r.getSyncServiceSettings(ctx, hohConfig)
+		if err != nil {
+			return nil, err
+		}
+		values.SyncService = *syncService
+	} else if getExternalKafka(hohConfig) != nil {
+		return nil, nil
+	} else {
+		kafkaConfig, err := getKafkaClusterConfig(hohConfig)
+		if err != nil {
+			return nil, err
 		}
+		values.Kafka = kafkaConfig
+	}
+
+	transportObjects, err := hohRenderer.Render("manifests/transport/"+getTransportType(hohConfig),
+		func(component string) (interface{}, error) {
+			return values, nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	// the kafka pods are created by strimzi, only the CSS uses the image pull secrets
+	if getTransportType(hohConfig) != string(hubofhubsv1alpha1.SyncServiceTransportProvider) {
+		return transportObjects, nil
+	}
+	pullSecrets, err := r.getImagePullSecretCopies(ctx, hohConfig, copyNamespaces(hohConfig, syncServiceNamespace)...)
+	if err != nil {
+		return nil, err
+	}
+	return addImagePullSecrets(transportObjects, pullSecrets), nil
+}
 
-		return dbConfig, err
+// renderHubConfig renders the hub-of-hubs Config with the runtime settings of the manager, the defaults are
+// the defaults of the GlobalConfig
+func (r *ConfigReconciler) renderHubConfig(_ context.Context, hohConfig *hubofhubsv1alpha1.Config,
+	hohRenderer renderer.Renderer,
+) ([]runtime.Object, error) {
+	values := hubConfigValues{
+		AggregationLevel: string(hubofhubsv1alpha1.Full),
+		HeartbeatIntervals: hubofhubsv1alpha1.HeartbeatIntervalConfig{
+			HoH:     uint64(defaultHeartbeatInterval.Seconds()),
+			LeafHub: uint64(defaultHeartbeatInterval.Seconds()),
+		},
+		EnableLocalPolicies: true,
+	}
+	if global := hohConfig.Spec.Global; global != nil {
+		if global.AggregationLevel != "" {
+			values.AggregationLevel = string(global.AggregationLevel)
+		}
+		if heartbeatInterval := global.HeartbeatInterval; heartbeatInterval != nil {
+			if heartbeatInterval.HoH > 0 {
+				values.HeartbeatIntervals.HoH = heartbeatInterval.HoH
+			}
+			if heartbeatInterval.LeafHub > 0 {
+				values.HeartbeatIntervals.LeafHub = heartbeatInterval.LeafHub
+			}
+		}
+		values.EnableLocalPolicies = global.EnableLocalPolicies
+	}
+
+	// the CRD of the Config is the one deployed to the leaf hubs, the manager and the agents share it
+	objects, err := hohRenderer.RenderWithFilter("manifests/agent", "agent-config-crd",
+		func(component string) (interface{}, error) {
+			return values, nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	hubConfigObjects, err := hohRenderer.Render("manifests/hub-config", func(component string) (interface{}, error) {
+		return values, nil
 	})
 	if err != nil {
-		return ctrl.Result{}, err
+		return nil, err
 	}
+	return append(objects, hubConfigObjects...), nil
+}
 
-	for _, obj := range transportObjects {
-		log.Info("Creating or updating object", "object", obj)
-		err := hohDeployer.Deploy(obj)
+// renderManager renders the hub-of-hubs manager, it needs the connection details of kafka for kafka transport
+func (r *ConfigReconciler) renderManager(ctx context.Context, hohConfig *hubofhubsv1alpha1.Config,
+	hohRenderer renderer.Renderer,
+) ([]runtime.Object, error) {
+	values := managerConfig{
+		Images:           getImages(hohConfig),
+		ImagePullSecrets: getImagePullSecrets(hohConfig),
+		TransportType:    getTransportType(hohConfig),
+		Settings:         getManagerSettings(hohConfig),
+		API:              getNonk8sAPISettings(hohConfig),
+	}
+	if values.TransportType == string(hubofhubsv1alpha1.KafkaTransportProvider) {
+		connection, err := r.getKafkaConnection(ctx, hohConfig)
 		if err != nil {
-			return ctrl.Result{}, err
+			return nil, err
 		}
+		values.Kafka = *connection
+	} else {
+		values.SyncServicePollingInterval = getPollingInterval(hohConfig)
 	}
 
-	managerObjects, err := hohRenderer.Render("manifests/manager", func(component string) (interface{}, error) {
-		managerConfig := struct {
-			Registry      string
-			ImageTag      string
-			TransportType string
-		}{
-			Registry:      "quay.io/open-cluster-management-hub-of-hubs",
-			ImageTag:      "latest",
-			TransportType: transportType,
-		}
+	// the manager reads the secrets from environment variables, it is restarted when they change
+	processURL, transportBridgeURL, err := r.getDatabaseURLs(ctx, hohConfig)
+	if err != nil {
+		return nil, err
+	}
+	values.SecretsHash = hashSecrets(processURL, transportBridgeURL, values.Kafka.ClientCert,
+		values.Kafka.ClientKey, values.Kafka.Username, values.Kafka.Password)
 
-		return managerConfig, err
+	managerObjects, err := hohRenderer.Render("manifests/manager", func(component string) (interface{}, error) {
+		return values, nil
 	})
 	if err != nil {
-		return ctrl.Result{}, err
+		return nil, err
+	}
+	pullSecrets, err := r.getImagePullSecretCopies(ctx, hohConfig, copyNamespaces(hohConfig, managerNamespace)...)
+	if err != nil {
+		return nil, err
 	}
+	return addImagePullSecrets(managerObjects, pullSecrets), nil
+}
 
-	for _, obj := range managerObjects {
-		log.Info("Creating or updating object", "object", obj)
-		err := hohDeployer.Deploy(obj)
-		if err != nil {
-			return ctrl.Result{}, err
+// hashSecrets returns a hash of the given secret values, the values are not recoverable from it
+func hashSecrets(values ...[]byte) string {
+	hash := sha256.New()
+	for _, value := range values {
+		// the length prefix keeps the boundaries of the values
+		fmt.Fprintf(hash, "%d:", len(value))
+		hash.Write(value)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// newRenderer returns the renderer of the manifests of the components, the templates of the overrides directory
+// and ConfigMap are layered on top of the embedded manifests. They are read on each reconciliation, so that
+// a template can be fixed without rebuilding or restarting the operator.
+func (r *ConfigReconciler) newRenderer(ctx context.Context) (renderer.Renderer, error) {
+	var overlays []iofs.FS
+	if r.ManifestOverridesDir != "" {
+		overlays = append(overlays, os.DirFS(r.ManifestOverridesDir))
+	}
+	if r.ManifestOverridesConfigMap.Name != "" {
+		configMap := &corev1.ConfigMap{}
+		err := r.Get(ctx, r.ManifestOverridesConfigMap, configMap)
+		if err != nil && !errors.IsNotFound(err) {
+			return nil, fmt.Errorf("failed to get the manifest overrides: %w", err)
+		}
+		if err == nil {
+			overlays = append(overlays, manifestOverrides(configMap))
+		}
+	}
+
+	if len(overlays) == 0 {
+		return renderer.NewHoHRenderer(fs), nil
+	}
+	return renderer.NewHoHRenderer(renderer.NewOverlayFS(fs, overlays...)), nil
+}
+
+// manifestOverrides returns the templates of the manifest overrides ConfigMap as a filesystem
+func manifestOverrides(configMap *corev1.ConfigMap) fstest.MapFS {
+	overrides := fstest.MapFS{}
+	for key, value := range configMap.Data {
+		overrides[strings.ReplaceAll(key, "__", "/")] = &fstest.MapFile{Data: []byte(value)}
+	}
+	for key, value := range configMap.BinaryData {
+		overrides[strings.ReplaceAll(key, "__", "/")] = &fstest.MapFile{Data: value}
+	}
+	return overrides
+}
+
+// newDeployer returns the deployer of the objects of the hub components
+func (r *ConfigReconciler) newDeployer() deployer.Deployer {
+	if r.ServerSideApply {
+		return deployer.NewApplyDeployer(r.Client, fieldManager)
+	}
+	return deployer.NewHoHDeployer(r.Client)
+}
+
+// deployObjects labels the given objects as owned by the Config and creates or updates them in order
+func (r *ConfigReconciler) deployObjects(ctx context.Context, hohConfig *hubofhubsv1alpha1.Config,
+	hohDeployer deployer.Deployer, objects []runtime.Object,
+) error {
+	log := ctrllog.FromContext(ctx)
+
+	for _, obj := range objects {
+		if err := setOwner(obj, hohConfig); err != nil {
+			return err
+		}
+
+		log.Info("Creating or updating object", objectKeysAndValues(obj)...)
+		if err := hohDeployer.Deploy(obj); err != nil {
+			return err
 		}
 	}
 
-	return ctrl.Result{}, nil
+	return nil
+}
+
+// getTransportType returns the transport type passed to the hub-of-hubs components
+func getTransportType(hohConfig *hubofhubsv1alpha1.Config) string {
+	if hohConfig.Spec.Components != nil && hohConfig.Spec.Components.Transport != nil &&
+		hohConfig.Spec.Components.Transport.Provider == hubofhubsv1alpha1.SyncServiceTransportProvider {
+		return string(hubofhubsv1alpha1.SyncServiceTransportProvider)
+	}
+	return string(hubofhubsv1alpha1.KafkaTransportProvider)
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *ConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	// reconcile the Config when a managed cluster becomes or stops being a leaf hub
+	leafHubPredicate := predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool {
+			return isLeafHub(e.Object)
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return isLeafHub(e.ObjectOld) != isLeafHub(e.ObjectNew)
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			return isLeafHub(e.Object)
+		},
+		GenericFunc: func(e event.GenericEvent) bool {
+			return false
+		},
+	}
+	// reconcile the Config when the status of an agent ManifestWork changes
+	agentManifestWorkPredicate := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return obj.GetName() == agentManifestWorkName && isLeafHub(obj)
+	})
+
+	controllerBuilder := ctrl.NewControllerManagedBy(mgr).
 		For(&hubofhubsv1alpha1.Config{}).
-		Complete(r)
+		Watches(&source.Kind{Type: &clusterv1.ManagedCluster{}},
+			handler.EnqueueRequestsFromMapFunc(r.enqueueConfigs),
+			builder.WithPredicates(leafHubPredicate)).
+		Watches(&source.Kind{Type: &workv1.ManifestWork{}},
+			handler.EnqueueRequestsFromMapFunc(r.enqueueConfigs),
+			builder.WithPredicates(agentManifestWorkPredicate)).
+		Watches(&source.Kind{Type: &corev1.Secret{}},
+			handler.EnqueueRequestsFromMapFunc(r.enqueueSecretReaders))
+	if r.ManifestOverridesConfigMap.Name != "" {
+		// render the manifests again when the overrides are changed
+		controllerBuilder = controllerBuilder.Watches(&source.Kind{Type: &corev1.ConfigMap{}},
+			handler.EnqueueRequestsFromMapFunc(r.enqueueConfigs),
+			builder.WithPredicates(predicate.NewPredicateFuncs(func(obj client.Object) bool {
+				return obj.GetNamespace() == r.ManifestOverridesConfigMap.Namespace &&
+					obj.GetName() == r.ManifestOverridesConfigMap.Name
+			})))
+	}
+
+	// reconcile the Config when an object deployed by the operator is changed or deleted
+	for _, owned := range ownedObjects() {
+		gvk, err := apiutil.GVKForObject(owned.object, mgr.GetScheme())
+		if err != nil {
+			return err
+		}
+		if _, err := mgr.GetRESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version); err != nil {
+			if meta.IsNoMatchError(err) {
+				// the CRD of the kind is not installed, e.g. strimzi is not installed for sync-service transport
+				ctrllog.Log.Info("Skipping the watch of an unavailable kind", "kind", gvk.String())
+				continue
+			}
+			return err
+		}
+
+		controllerBuilder = controllerBuilder.Watches(&source.Kind{Type: owned.object},
+			handler.EnqueueRequestsFromMapFunc(r.enqueueOwner),
+			builder.WithPredicates(ownedObjectPredicate(owned)))
+	}
+
+	return controllerBuilder.Complete(r)
+}
+
+// enqueueConfigs maps an event of a dependent object to reconcile requests of all the Configs
+func (r *ConfigReconciler) enqueueConfigs(obj client.Object) []reconcile.Request {
+	configList := &hubofhubsv1alpha1.ConfigList{}
+	if err := r.List(context.TODO(), configList); err != nil {
+		ctrllog.Log.Error(err, "Failed to list Configs")
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(configList.Items))
+	for _, config := range configList.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: config.Namespace, Name: config.Name},
+		})
+	}
+
+	return requests
+}
+
+// enqueueSecretReaders maps an event of a secret to the reconcile requests of the Configs that read it,
+// so that the database URL secrets and the credentials are updated when the passwords are rotated
+func (r *ConfigReconciler) enqueueSecretReaders(obj client.Object) []reconcile.Request {
+	// the user secrets of the PostgresCluster are read by the Configs that don't use an external database
+	isPostgresUserSecret := obj.GetNamespace() == postgresNamespace &&
+		strings.HasPrefix(obj.GetName(), postgresUserSecretBase)
+
+	configList := &hubofhubsv1alpha1.ConfigList{}
+	listOptions := []client.ListOption{}
+	if !isPostgresUserSecret {
+		listOptions = append(listOptions, client.InNamespace(obj.GetNamespace()))
+	}
+	if err := r.List(context.TODO(), configList, listOptions...); err != nil {
+		ctrllog.Log.Error(err, "Failed to list Configs")
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for i := range configList.Items {
+		hohConfig := &configList.Items[i]
+		if (isPostgresUserSecret && getExternalDatabase(hohConfig) == nil) ||
+			(hohConfig.Namespace == obj.GetNamespace() && referencedSecrets(hohConfig).Has(obj.GetName())) {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: hohConfig.Namespace, Name: hohConfig.Name},
+			})
+		}
+	}
+
+	return requests
+}
+
+// referencedSecrets returns the names of the secrets in the namespace of the Config that are referred by its spec
+func referencedSecrets(hohConfig *hubofhubsv1alpha1.Config) sets.String {
+	names := sets.NewString()
+	if external := getExternalDatabase(hohConfig); external != nil {
+		names.Insert(external.ProcessUserSecret.Name, external.TransportBridgeUserSecret.Name)
+		if external.AdminSecret != nil {
+			names.Insert(external.AdminSecret.Name)
+		}
+	}
+	for _, pullSecret := range getImagePullSecrets(hohConfig) {
+		names.Insert(pullSecret.Name)
+	}
+	if external := getExternalKafka(hohConfig); external != nil {
+		if external.CASecret != nil {
+			names.Insert(external.CASecret.Name)
+		}
+		if external.Authentication != nil {
+			names.Insert(external.Authentication.Secret.Name)
+		}
+	}
+	return names
+}
+
+func isLeafHub(obj client.Object) bool {
+	return obj.GetLabels()[hubofhubsv1alpha1.LeafHubLabelKey] == "true"
 }
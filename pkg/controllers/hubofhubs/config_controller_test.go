@@ -0,0 +1,395 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hubofhubs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	hubofhubsv1alpha1 "github.com/stolostron/hub-of-hubs-operator/apis/hubofhubs/v1alpha1"
+	"github.com/stolostron/hub-of-hubs-operator/pkg/renderer"
+)
+
+// kindsAndNames returns the kind and the name of each of the given objects
+func kindsAndNames(t *testing.T, objects []runtime.Object) []string {
+	t.Helper()
+	result := []string{}
+	for _, obj := range objects {
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result = append(result, obj.GetObjectKind().GroupVersionKind().Kind+"/"+accessor.GetName())
+	}
+	return result
+}
+
+// containerArgsAndEnv returns the arguments and the environment variables of the first container of the Deployment
+// with the given name, the variables that are read from secrets have an empty value
+func containerArgsAndEnv(t *testing.T, objects []runtime.Object, name string) ([]string, map[string]string) {
+	t.Helper()
+	for _, obj := range objects {
+		deployment, ok := obj.(*unstructured.Unstructured)
+		if !ok || deployment.GetKind() != "Deployment" || deployment.GetName() != name {
+			continue
+		}
+		containers, _, _ := unstructured.NestedSlice(deployment.Object, "spec", "template", "spec", "containers")
+		if len(containers) == 0 {
+			t.Fatalf("the deployment %s has no container", name)
+		}
+		container := containers[0].(map[string]interface{})
+		args, _, _ := unstructured.NestedStringSlice(container, "args")
+		envVars, _, _ := unstructured.NestedSlice(container, "env")
+		env := map[string]string{}
+		for _, envVar := range envVars {
+			name, _, _ := unstructured.NestedString(envVar.(map[string]interface{}), "name")
+			env[name], _, _ = unstructured.NestedString(envVar.(map[string]interface{}), "value")
+		}
+		return args, env
+	}
+	t.Fatalf("the deployment %s is not rendered", name)
+	return nil, nil
+}
+
+// checkTransportArgs checks that the container has the wanted arguments and that the kafka arguments,
+// environment variables and credentials secret are only rendered for kafka transport
+func checkTransportArgs(t *testing.T, objects []runtime.Object, deploymentName, secretName string,
+	wantArgs []string, wantKafka bool,
+) {
+	t.Helper()
+	args, env := containerArgsAndEnv(t, objects, deploymentName)
+	rendered := map[string]bool{}
+	hasKafkaArgs := false
+	for _, arg := range args {
+		rendered[arg] = true
+		hasKafkaArgs = hasKafkaArgs || strings.HasPrefix(arg, "--kafka-")
+	}
+	for _, arg := range wantArgs {
+		if !rendered[arg] {
+			t.Errorf("the argument %s is not rendered, the arguments are %v", arg, args)
+		}
+	}
+	if hasKafkaArgs != wantKafka {
+		t.Errorf("the kafka arguments are rendered: %t, want %t", hasKafkaArgs, wantKafka)
+	}
+
+	hasKafkaEnv := false
+	for name := range env {
+		hasKafkaEnv = hasKafkaEnv || strings.HasPrefix(name, "KAFKA_")
+	}
+	if hasKafkaEnv != wantKafka {
+		t.Errorf("the kafka environment variables are rendered: %t, want %t", hasKafkaEnv, wantKafka)
+	}
+
+	hasSecret := false
+	for _, kindAndName := range kindsAndNames(t, objects) {
+		hasSecret = hasSecret || kindAndName == "Secret/"+secretName
+	}
+	if hasSecret != wantKafka {
+		t.Errorf("the secret %s is rendered: %t, want %t", secretName, hasSecret, wantKafka)
+	}
+}
+
+func TestRenderManagerArgs(t *testing.T) {
+	hohConfig := newTestConfig()
+	kafka := kafkaConnection{BootstrapServer: "kafka.example.com:443", CA: "Y2E="}
+
+	tests := []struct {
+		name      string
+		values    managerConfig
+		wantArgs  []string
+		wantKafka bool
+	}{
+		{
+			name: "kafka",
+			values: managerConfig{
+				TransportType: string(hubofhubsv1alpha1.KafkaTransportProvider),
+				Kafka:         kafka,
+				Settings:      getManagerSettings(hohConfig),
+				API:           getNonk8sAPISettings(hohConfig),
+			},
+			wantArgs: []string{
+				"--transport-type=kafka",
+				"--kafka-bootstrap-server=kafka.example.com:443",
+				"--kafka-ssl-ca=Y2E=",
+				"--kafka-message-size-limit=940",
+				"--status-sync-interval=5s",
+				"--spec-sync-interval=5s",
+				"--transport-message-compression-type=gzip",
+				"--transport-committer-interval=5s",
+				"--statistics-log-interval=5s",
+				"--server-base-path=/multicloud/hub-of-hubs-nonk8s-api",
+			},
+			wantKafka: true,
+		},
+		{
+			name: "sync-service",
+			values: managerConfig{
+				TransportType:              string(hubofhubsv1alpha1.SyncServiceTransportProvider),
+				Settings:                   getManagerSettings(hohConfig),
+				API:                        getNonk8sAPISettings(hohConfig),
+				SyncServicePollingInterval: 10,
+			},
+			wantArgs: []string{
+				"--transport-type=sync-service",
+				"--cloud-sync-service-protocol=https",
+				"--cloud-sync-service-polling-interval=10",
+				"--status-sync-interval=5s",
+				"--spec-sync-interval=5s",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects, err := renderer.NewHoHRenderer(fs).Render("manifests/manager",
+				func(component string) (interface{}, error) {
+					return tt.values, nil
+				})
+			if err != nil {
+				t.Fatalf("failed to render: %v", err)
+			}
+			checkTransportArgs(t, objects, "hub-of-hubs-manager", "hub-of-hubs-kafka-credentials", tt.wantArgs,
+				tt.wantKafka)
+		})
+	}
+}
+
+func TestRenderHubConfig(t *testing.T) {
+	r := newTestReconciler(t)
+	objects, err := r.renderHubConfig(context.TODO(), newTestConfig(), renderer.NewHoHRenderer(fs))
+	if err != nil {
+		t.Fatalf("renderHubConfig() failed: %v", err)
+	}
+	// the CRD is rendered from the agent manifests
+	want := []string{
+		"CustomResourceDefinition/configs.hub-of-hubs.open-cluster-management.io",
+		"Namespace/hoh-system",
+		"Config/hub-of-hubs-config",
+	}
+	if got := kindsAndNames(t, objects); !reflect.DeepEqual(got, want) {
+		t.Errorf("rendered %v, want %v", got, want)
+	}
+}
+
+func TestDataBearingObjects(t *testing.T) {
+	kafkaConfig, err := getKafkaClusterConfig(newTestConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		component string
+		values    interface{}
+		want      []string
+	}{
+		{
+			component: "manifests/database",
+			values:    databaseConfig{PostgresReplicas: 1, PgBouncerReplicas: 1},
+			want:      []string{"Namespace/hoh-postgres", "PostgresCluster/hoh"},
+		},
+		{
+			component: "manifests/transport/kafka",
+			values:    transportConfig{Kafka: kafkaConfig},
+			want:      []string{"Kafka/kafka-brokers-cluster"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.component, func(t *testing.T) {
+			objects, err := renderer.NewHoHRenderer(fs).Render(tt.component,
+				func(component string) (interface{}, error) {
+					return tt.values, nil
+				})
+			if err != nil {
+				t.Fatalf("failed to render: %v", err)
+			}
+			var dataBearing []runtime.Object
+			for _, obj := range objects {
+				if isDataBearing(obj) {
+					dataBearing = append(dataBearing, obj)
+				}
+			}
+			if got := kindsAndNames(t, dataBearing); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("the data-bearing objects are %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderManagerIngress(t *testing.T) {
+	tests := []struct {
+		name            string
+		nonk8sAPI       *hubofhubsv1alpha1.Nonk8sAPIConfig
+		wantClassName   string
+		wantAnnotations map[string]string
+	}{
+		{
+			name:            "management ingress",
+			wantClassName:   defaultIngressClassName,
+			wantAnnotations: defaultNonk8sAPIAnnotations,
+		},
+		{
+			name: "ingress class and annotations",
+			nonk8sAPI: &hubofhubsv1alpha1.Nonk8sAPIConfig{
+				IngressClassName: "nginx",
+				// the legacy annotation of the class is kept as given, the class is set in the spec
+				Annotations: map[string]string{"kubernetes.io/ingress.class": "nginx", "example.com/key": "value"},
+			},
+			wantClassName:   "nginx",
+			wantAnnotations: map[string]string{"kubernetes.io/ingress.class": "nginx", "example.com/key": "value"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hohConfig := newTestConfig()
+			if tt.nonk8sAPI != nil {
+				hohConfig.Spec.Components = &hubofhubsv1alpha1.ComponentsConfig{
+					Core: &hubofhubsv1alpha1.CoreConfig{Hoh: &hubofhubsv1alpha1.HohConfig{Nonk8sAPI: tt.nonk8sAPI}},
+				}
+			}
+			values := managerConfig{API: getNonk8sAPISettings(hohConfig)}
+
+			objects, err := renderer.NewHoHRenderer(fs).Render("manifests/manager",
+				func(component string) (interface{}, error) {
+					return values, nil
+				})
+			if err != nil {
+				t.Fatalf("failed to render: %v", err)
+			}
+			var ingress *unstructured.Unstructured
+			for _, obj := range objects {
+				if obj.GetObjectKind().GroupVersionKind().Kind == "Ingress" {
+					ingress = obj.(*unstructured.Unstructured)
+				}
+			}
+			if ingress == nil {
+				t.Fatal("the ingress is not rendered")
+			}
+
+			className, _, _ := unstructured.NestedString(ingress.Object, "spec", "ingressClassName")
+			if className != tt.wantClassName {
+				t.Errorf("the ingress class is %q, want %q", className, tt.wantClassName)
+			}
+			if got := ingress.GetAnnotations(); !reflect.DeepEqual(got, tt.wantAnnotations) {
+				t.Errorf("the annotations are %v, want %v", got, tt.wantAnnotations)
+			}
+		})
+	}
+}
+
+func TestNewRendererWithOverrides(t *testing.T) {
+	namespaceOverride := "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: hoh-system\n" +
+		"  labels:\n    overridden: \"true\"\n"
+	extraTemplate := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: extra\n  namespace: hoh-system\n"
+
+	overridesDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(overridesDir, "manifests", "hub-config"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(overridesDir, "manifests", "hub-config", "extra.yaml"),
+		[]byte(extraTemplate), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	overrides := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "open-cluster-management", Name: "manifest-overrides"},
+		Data:       map[string]string{"manifests__hub-config__namespace.yaml": namespaceOverride},
+	}
+	values := hubConfigValues{AggregationLevel: "full"}
+
+	tests := []struct {
+		name           string
+		dir            string
+		configMap      types.NamespacedName
+		want           []string
+		wantOverridden bool
+	}{
+		{
+			name: "embedded manifests",
+			want: []string{
+				"Namespace/hoh-system",
+				"Config/hub-of-hubs-config",
+			},
+		},
+		{
+			name:      "configmap override",
+			configMap: types.NamespacedName{Namespace: "open-cluster-management", Name: "manifest-overrides"},
+			want: []string{
+				"Namespace/hoh-system",
+				"Config/hub-of-hubs-config",
+			},
+			wantOverridden: true,
+		},
+		{
+			name: "directory with an added template",
+			dir:  overridesDir,
+			want: []string{
+				"ConfigMap/extra",
+				"Namespace/hoh-system",
+				"Config/hub-of-hubs-config",
+			},
+		},
+		{
+			name:      "missing configmap",
+			configMap: types.NamespacedName{Namespace: "open-cluster-management", Name: "missing"},
+			want: []string{
+				"Namespace/hoh-system",
+				"Config/hub-of-hubs-config",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestReconciler(t, overrides)
+			r.ManifestOverridesDir = tt.dir
+			r.ManifestOverridesConfigMap = tt.configMap
+
+			hohRenderer, err := r.newRenderer(context.TODO())
+			if err != nil {
+				t.Fatalf("newRenderer() failed: %v", err)
+			}
+			objects, err := hohRenderer.Render("manifests/hub-config", func(component string) (interface{}, error) {
+				return values, nil
+			})
+			if err != nil {
+				t.Fatalf("failed to render: %v", err)
+			}
+			if got := kindsAndNames(t, objects); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("rendered %v, want %v", got, tt.want)
+			}
+
+			for _, obj := range objects {
+				if obj.GetObjectKind().GroupVersionKind().Kind != "Namespace" {
+					continue
+				}
+				accessor, _ := meta.Accessor(obj)
+				if overridden := accessor.GetLabels()["overridden"] == "true"; overridden != tt.wantOverridden {
+					t.Errorf("the namespace is overridden: %t, want %t", overridden, tt.wantOverridden)
+				}
+			}
+		})
+	}
+}
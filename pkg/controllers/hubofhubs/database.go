@@ -0,0 +1,294 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hubofhubs
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	hubofhubsv1alpha1 "github.com/stolostron/hub-of-hubs-operator/apis/hubofhubs/v1alpha1"
+	"github.com/stolostron/hub-of-hubs-operator/pkg/renderer"
+)
+
+const (
+	defaultPostgresPort = "5432"
+	// haPostgresReplicas is the number of instances and pgBouncer replicas of the PostgresCluster in HA mode
+	haPostgresReplicas = 2
+)
+
+// databaseSecrets are the connection secrets of the database users, in the format of the Crunchy PGO user secrets
+type databaseSecrets struct {
+	admin           *corev1.Secret
+	processUser     *corev1.Secret
+	transportBridge *corev1.Secret
+}
+
+// getExternalDatabase returns the settings of the external database, or nil if the database is deployed
+// by the operator
+func getExternalDatabase(hohConfig *hubofhubsv1alpha1.Config) *hubofhubsv1alpha1.ExternalPostgreSqlConfig {
+	if components := hohConfig.Spec.Components; components != nil && components.Database != nil &&
+		components.Database.Postgresql != nil {
+		return components.Database.Postgresql.External
+	}
+	return nil
+}
+
+// renderDatabase renders the PostgresCluster and its configuration, nothing is deployed for an external database
+func (r *ConfigReconciler) renderDatabase(ctx context.Context, hohConfig *hubofhubsv1alpha1.Config,
+	hohRenderer renderer.Renderer,
+) ([]runtime.Object, error) {
+	if getExternalDatabase(hohConfig) != nil {
+		return nil, nil
+	}
+
+	var storage *hubofhubsv1alpha1.PostgreSqlStorageConfig
+	var postgresql *hubofhubsv1alpha1.PostgreSqlConfig
+	if components := hohConfig.Spec.Components; components != nil && components.Database != nil {
+		postgresql = components.Database.Postgresql
+	}
+	if postgresql != nil {
+		storage = postgresql.Storage
+	}
+	dataSize, backupSize := storage.GetDataSize(), storage.GetBackupSize()
+
+	// PGO expands the existing volumes when their requested size is increased
+	values := databaseConfig{
+		Images:            getImages(hohConfig),
+		ImagePullSecrets:  getImagePullSecrets(hohConfig),
+		PostgresReplicas:  1,
+		PgBouncerReplicas: 1,
+		DataVolumeSize:    dataSize.String(),
+		BackupVolumeSize:  backupSize.String(),
+		StorageClassName:  storage.GetStorageClassName(),
+	}
+	if postgresql != nil {
+		values.EnableHA = postgresql.EnableHA
+		if postgresql.Replicas > 0 {
+			values.PostgresReplicas = postgresql.Replicas
+		}
+		if postgresql.EnableHA {
+			values.PgBouncerReplicas = haPostgresReplicas
+			if postgresql.Replicas == 0 {
+				values.PostgresReplicas = haPostgresReplicas
+			}
+		}
+		if resources := postgresql.Resources; resources != nil {
+			values.ResourceRequests = resourceListToStrings(resources.Requests)
+			values.ResourceLimits = resourceListToStrings(resources.Limits)
+		}
+	}
+
+	// the pgo-config is rendered before the PostgresCluster, so that the automatic failover is disabled
+	// before the replicas are removed when HA is turned off
+	objects, err := hohRenderer.Render("manifests/database", func(component string) (interface{}, error) {
+		return values, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	pullSecrets, err := r.getImagePullSecretCopies(ctx, hohConfig, copyNamespaces(hohConfig, postgresNamespace)...)
+	if err != nil {
+		return nil, err
+	}
+	return addImagePullSecrets(objects, pullSecrets), nil
+}
+
+// resourceListToStrings returns the quantities of a resource list as strings, so that they can be rendered
+func resourceListToStrings(resources corev1.ResourceList) map[string]string {
+	if len(resources) == 0 {
+		return nil
+	}
+	quantities := make(map[string]string, len(resources))
+	for name, quantity := range resources {
+		quantities[string(name)] = quantity.String()
+	}
+	return quantities
+}
+
+// renderDatabaseInit renders the database URL secrets of the manager and the database initialization job
+func (r *ConfigReconciler) renderDatabaseInit(ctx context.Context, hohConfig *hubofhubsv1alpha1.Config,
+	hohRenderer renderer.Renderer,
+) ([]runtime.Object, error) {
+	values := databaseConfig{
+		Images:           getImages(hohConfig),
+		ImagePullSecrets: getImagePullSecrets(hohConfig),
+		JobNamespace:     postgresNamespace,
+		AdminSecret:      postgresUserSecretBase + "postgres",
+	}
+
+	// the job of an external database runs in the namespace of the Config to read the given secrets
+	if external := getExternalDatabase(hohConfig); external != nil {
+		values.JobNamespace = hohConfig.GetNamespace()
+		values.AdminSecret = external.ProcessUserSecret.Name
+		if external.AdminSecret != nil && external.AdminSecret.Name != "" {
+			values.AdminSecret = external.AdminSecret.Name
+		}
+	}
+
+	var err error
+	values.ProcessDatabaseURL, values.TransportBridgeDatabaseURL, err = r.getDatabaseURLs(ctx, hohConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	objects, err := hohRenderer.Render("manifests/database-secrets", func(component string) (interface{}, error) {
+		return values, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	jobObjects, err := hohRenderer.Render("manifests/database-init", func(component string) (interface{}, error) {
+		return values, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// the URL secrets are created before the job, so that the manager can start once the job is done
+	return append(objects, jobObjects...), nil
+}
+
+// getDatabaseURLs returns the connection URLs of the process user and the transport bridge user, read from
+// the secrets of the external database or from the user secrets that PGO creates for the PostgresCluster
+func (r *ConfigReconciler) getDatabaseURLs(ctx context.Context, hohConfig *hubofhubsv1alpha1.Config,
+) ([]byte, []byte, error) {
+	var processUserSecret, transportBridgeSecret *corev1.Secret
+	if external := getExternalDatabase(hohConfig); external != nil {
+		secrets, err := r.getExternalDatabaseSecrets(ctx, hohConfig, external)
+		if err != nil {
+			return nil, nil, err
+		}
+		processUserSecret, transportBridgeSecret = secrets.processUser, secrets.transportBridge
+	} else {
+		getSecret := func(user string) (*corev1.Secret, error) {
+			secret := &corev1.Secret{}
+			if err := r.Get(ctx, types.NamespacedName{Namespace: postgresNamespace,
+				Name: postgresUserSecretBase + user}, secret); err != nil {
+				return nil, fmt.Errorf("failed to get the database secret %s: %w", postgresUserSecretBase+user, err)
+			}
+			return secret, nil
+		}
+		var err error
+		if processUserSecret, err = getSecret(postgresProcessUser); err != nil {
+			return nil, nil, err
+		}
+		if transportBridgeSecret, err = getSecret(postgresTransportBridgeUser); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	processURL, err := databaseURL(processUserSecret)
+	if err != nil {
+		return nil, nil, err
+	}
+	transportBridgeURL, err := databaseURL(transportBridgeSecret)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return processURL, transportBridgeURL, nil
+}
+
+// getExternalDatabaseSecrets reads the connection secrets of an external database
+func (r *ConfigReconciler) getExternalDatabaseSecrets(ctx context.Context, hohConfig *hubofhubsv1alpha1.Config,
+	external *hubofhubsv1alpha1.ExternalPostgreSqlConfig,
+) (*databaseSecrets, error) {
+	getSecret := func(name string) (*corev1.Secret, error) {
+		secret := &corev1.Secret{}
+		if err := r.Get(ctx, types.NamespacedName{Namespace: hohConfig.GetNamespace(), Name: name},
+			secret); err != nil {
+			return nil, fmt.Errorf("failed to get the database secret %s: %w", name, err)
+		}
+		return secret, nil
+	}
+
+	var secrets databaseSecrets
+	var err error
+	if secrets.processUser, err = getSecret(external.ProcessUserSecret.Name); err != nil {
+		return nil, err
+	}
+	if secrets.transportBridge, err = getSecret(external.TransportBridgeUserSecret.Name); err != nil {
+		return nil, err
+	}
+	secrets.admin = secrets.processUser
+	if external.AdminSecret != nil && external.AdminSecret.Name != "" {
+		if secrets.admin, err = getSecret(external.AdminSecret.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	return &secrets, nil
+}
+
+// externalDatabaseReady checks that the connection secrets of the external database are complete
+func (r *ConfigReconciler) externalDatabaseReady(ctx context.Context, hohConfig *hubofhubsv1alpha1.Config,
+	external *hubofhubsv1alpha1.ExternalPostgreSqlConfig,
+) (bool, string, error) {
+	secrets, err := r.getExternalDatabaseSecrets(ctx, hohConfig, external)
+	if err != nil {
+		return false, "", err
+	}
+
+	for _, secret := range []*corev1.Secret{secrets.processUser, secrets.transportBridge} {
+		if _, err := databaseURL(secret); err != nil {
+			return false, "", err
+		}
+	}
+	// the initialization job reads the connection settings of the admin user from separate keys
+	for _, key := range []string{"host", "user", "password"} {
+		if len(secrets.admin.Data[key]) == 0 {
+			return false, "", fmt.Errorf("the database secret %s has no %s key", secrets.admin.GetName(), key)
+		}
+	}
+
+	return true, "", nil
+}
+
+// databaseURL returns the connection URL of a user secret in the format of the Crunchy PGO user secrets.
+// The pgBouncer URL is preferred so that the connections are pooled, and the URL is built from the host,
+// port, user, password and dbname keys when the secret has no URL.
+func databaseURL(secret *corev1.Secret) ([]byte, error) {
+	for _, key := range []string{"pgbouncer-uri", "uri"} {
+		if uri := secret.Data[key]; len(uri) > 0 {
+			return uri, nil
+		}
+	}
+
+	for _, key := range []string{"host", "user", "password", "dbname"} {
+		if len(secret.Data[key]) == 0 {
+			return nil, fmt.Errorf("the database secret %s has no %s key", secret.GetName(), key)
+		}
+	}
+	port := string(secret.Data["port"])
+	if port == "" {
+		port = defaultPostgresPort
+	}
+
+	databaseURL := url.URL{
+		Scheme: "postgres",
+		User:   url.UserPassword(string(secret.Data["user"]), string(secret.Data["password"])),
+		Host:   net.JoinHostPort(string(secret.Data["host"]), port),
+		Path:   "/" + string(secret.Data["dbname"]),
+	}
+	return []byte(databaseURL.String()), nil
+}
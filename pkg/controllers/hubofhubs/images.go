@@ -0,0 +1,170 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hubofhubs
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	hubofhubsv1alpha1 "github.com/stolostron/hub-of-hubs-operator/apis/hubofhubs/v1alpha1"
+)
+
+const (
+	defaultImageRegistry  = "quay.io/open-cluster-management-hub-of-hubs"
+	defaultImageTag       = "latest"
+	defaultSyncServiceTag = "stable"
+	crunchyImageRegistry  = "registry.developers.crunchydata.com/crunchydata"
+	postgresImage         = "/crunchy-postgres:centos8-13.4-1"
+	pgBackRestImage       = "/crunchy-pgbackrest:centos8-2.35-0"
+	pgBouncerImage        = "/crunchy-pgbouncer:centos8-1.15-3"
+)
+
+// images contains the full image references of the hub-of-hubs components
+type images struct {
+	Manager        string
+	Agent          string
+	DatabaseInit   string
+	Postgres       string
+	PgBackRest     string
+	PgBouncer      string
+	SyncServiceCSS string
+	SyncServiceESS string
+}
+
+// getImages returns the images of the components, built from the image registry and tag of the global config
+// and the version of the sync-service unless they are overridden. The PostgreSQL images are pulled from the
+// crunchy registry unless another image registry is set, e.g. a mirror, they keep the versions supported by PGO.
+func getImages(hohConfig *hubofhubsv1alpha1.Config) images {
+	registry, tag, syncServiceTag := defaultImageRegistry, defaultImageTag, defaultSyncServiceTag
+	postgresRegistry := crunchyImageRegistry
+	var overrides *hubofhubsv1alpha1.ImageOverridesConfig
+	if syncService := getSyncServiceConfig(hohConfig); syncService != nil && syncService.Version != "" {
+		syncServiceTag = syncService.Version
+	}
+	if global := hohConfig.Spec.Global; global != nil {
+		if global.ImageRegistry != "" {
+			registry = global.ImageRegistry
+		}
+		// the default registry, which is also the default of the CRD, doesn't host the PostgreSQL images
+		if global.ImageRegistry != "" && global.ImageRegistry != defaultImageRegistry {
+			postgresRegistry = global.ImageRegistry
+		}
+		if global.ImageTag != "" {
+			tag = global.ImageTag
+		}
+		overrides = global.ImageOverrides
+	}
+
+	componentImages := images{
+		Manager:        registry + "/hub-of-hubs-manager:" + tag,
+		Agent:          registry + "/hub-of-hubs-agent:" + tag,
+		DatabaseInit:   registry + "/postgresql-ansible:" + tag,
+		Postgres:       postgresRegistry + postgresImage,
+		PgBackRest:     postgresRegistry + pgBackRestImage,
+		PgBouncer:      postgresRegistry + pgBouncerImage,
+		SyncServiceCSS: registry + "/hub-of-hubs-sync-service-css:" + syncServiceTag,
+		SyncServiceESS: registry + "/leaf-hub-sync-service-ess:" + syncServiceTag,
+	}
+	if overrides == nil {
+		return componentImages
+	}
+
+	overrideImage(&componentImages.Manager, overrides.Manager)
+	overrideImage(&componentImages.Agent, overrides.Agent)
+	overrideImage(&componentImages.DatabaseInit, overrides.DatabaseInit)
+	overrideImage(&componentImages.Postgres, overrides.Postgres)
+	overrideImage(&componentImages.PgBackRest, overrides.PgBackRest)
+	overrideImage(&componentImages.PgBouncer, overrides.PgBouncer)
+	overrideImage(&componentImages.SyncServiceCSS, overrides.SyncServiceCSS)
+	overrideImage(&componentImages.SyncServiceESS, overrides.SyncServiceESS)
+
+	return componentImages
+}
+
+func overrideImage(image *string, override string) {
+	if override != "" {
+		*image = override
+	}
+}
+
+// getImagePullSecrets returns the image pull secrets added to the hub-of-hubs pods
+func getImagePullSecrets(hohConfig *hubofhubsv1alpha1.Config) []corev1.LocalObjectReference {
+	if hohConfig.Spec.Global == nil {
+		return nil
+	}
+	return hohConfig.Spec.Global.ImagePullSecrets
+}
+
+// getImagePullSecretCopies returns copies of the image pull secrets of the Config in the given namespaces,
+// the pods can only reference the secrets of their own namespace. The secrets are read from the namespace
+// of the Config.
+func (r *ConfigReconciler) getImagePullSecretCopies(ctx context.Context, hohConfig *hubofhubsv1alpha1.Config,
+	namespaces ...string,
+) ([]runtime.Object, error) {
+	var copies []runtime.Object
+	for _, ref := range getImagePullSecrets(hohConfig) {
+		secret := &corev1.Secret{}
+		if err := r.Get(ctx, types.NamespacedName{Namespace: hohConfig.GetNamespace(), Name: ref.Name},
+			secret); err != nil {
+			return nil, fmt.Errorf("failed to get the image pull secret %s: %w", ref.Name, err)
+		}
+		for _, namespace := range namespaces {
+			copies = append(copies, &corev1.Secret{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+				ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: secret.Name},
+				Type:       secret.Type,
+				Data:       secret.Data,
+			})
+		}
+	}
+	return copies, nil
+}
+
+// copyNamespaces returns the given namespaces of the hub-of-hubs cluster that the image pull secrets are copied to,
+// the namespace of the Config already has them
+func copyNamespaces(hohConfig *hubofhubsv1alpha1.Config, namespaces ...string) []string {
+	result := make([]string, 0, len(namespaces))
+	for _, namespace := range namespaces {
+		if namespace != hohConfig.GetNamespace() {
+			result = append(result, namespace)
+		}
+	}
+	return result
+}
+
+// addImagePullSecrets inserts the image pull secrets after the last namespace of the rendered objects,
+// so that they are deployed in the namespaces before the pods that use them
+func addImagePullSecrets(objects, pullSecrets []runtime.Object) []runtime.Object {
+	if len(pullSecrets) == 0 {
+		return objects
+	}
+	position := 0
+	for i, obj := range objects {
+		if obj.GetObjectKind().GroupVersionKind().Kind == "Namespace" {
+			position = i + 1
+		}
+	}
+	result := make([]runtime.Object, 0, len(objects)+len(pullSecrets))
+	result = append(result, objects[:position]...)
+	result = append(result, pullSecrets...)
+	return append(result, objects[position:]...)
+}
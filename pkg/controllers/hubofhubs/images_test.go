@@ -0,0 +1,208 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hubofhubs
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	hubofhubsv1alpha1 "github.com/stolostron/hub-of-hubs-operator/apis/hubofhubs/v1alpha1"
+)
+
+// namespacedNames returns the kind, namespace and name of each of the given objects
+func namespacedNames(t *testing.T, objects []runtime.Object) []string {
+	t.Helper()
+	result := []string{}
+	for _, obj := range objects {
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result = append(result, obj.GetObjectKind().GroupVersionKind().Kind+"/"+accessor.GetNamespace()+"/"+
+			accessor.GetName())
+	}
+	return result
+}
+
+func TestGetImagePullSecretCopies(t *testing.T) {
+	pullSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "open-cluster-management", Name: "pull-secret"},
+		Type:       corev1.SecretTypeDockerConfigJson,
+		Data:       map[string][]byte{corev1.DockerConfigJsonKey: []byte("{}")},
+	}
+
+	tests := []struct {
+		name        string
+		pullSecrets []corev1.LocalObjectReference
+		namespaces  []string
+		want        []string
+		wantErr     bool
+	}{
+		{
+			name:       "no image pull secrets",
+			namespaces: []string{postgresNamespace},
+			want:       []string{},
+		},
+		{
+			name:        "copied to the namespaces of the pods",
+			pullSecrets: []corev1.LocalObjectReference{{Name: "pull-secret"}},
+			namespaces:  []string{postgresNamespace, syncServiceNamespace},
+			want:        []string{"Secret/hoh-postgres/pull-secret", "Secret/sync-service/pull-secret"},
+		},
+		{
+			name:        "not copied to the namespace of the Config",
+			pullSecrets: []corev1.LocalObjectReference{{Name: "pull-secret"}},
+			namespaces:  []string{managerNamespace},
+			want:        []string{},
+		},
+		{
+			name:        "missing image pull secret",
+			pullSecrets: []corev1.LocalObjectReference{{Name: "missing"}},
+			namespaces:  []string{postgresNamespace},
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hohConfig := newTestConfig()
+			hohConfig.Spec.Global = &hubofhubsv1alpha1.GlobalConfig{ImagePullSecrets: tt.pullSecrets}
+			r := newTestReconciler(t, pullSecret)
+
+			copies, err := r.getImagePullSecretCopies(context.TODO(), hohConfig,
+				copyNamespaces(hohConfig, tt.namespaces...)...)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error for the missing image pull secret")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("getImagePullSecretCopies() failed: %v", err)
+			}
+			if got := namespacedNames(t, copies); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("copied %v, want %v", got, tt.want)
+			}
+			for _, obj := range copies {
+				secret := obj.(*corev1.Secret)
+				if secret.Type != pullSecret.Type || !reflect.DeepEqual(secret.Data, pullSecret.Data) {
+					t.Errorf("the copy %s/%s doesn't have the type and data of the secret",
+						secret.Namespace, secret.Name)
+				}
+			}
+		})
+	}
+}
+
+func TestAddImagePullSecrets(t *testing.T) {
+	newObject := func(kind, namespace, name string) runtime.Object {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("v1")
+		obj.SetKind(kind)
+		obj.SetNamespace(namespace)
+		obj.SetName(name)
+		return obj
+	}
+	namespace := newObject("Namespace", "", "hoh")
+	pullSecret := &corev1.Secret{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: "hoh", Name: "pull-secret"},
+	}
+
+	tests := []struct {
+		name    string
+		objects []runtime.Object
+		want    []string
+	}{
+		{
+			name:    "after the namespace",
+			objects: []runtime.Object{namespace, newObject("ConfigMap", "hoh", "a")},
+			want:    []string{"Namespace//hoh", "Secret/hoh/pull-secret", "ConfigMap/hoh/a"},
+		},
+		{
+			name:    "before the other objects",
+			objects: []runtime.Object{newObject("ConfigMap", "hoh", "a"), newObject("ConfigMap", "hoh", "b")},
+			want:    []string{"Secret/hoh/pull-secret", "ConfigMap/hoh/a", "ConfigMap/hoh/b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects := addImagePullSecrets(tt.objects, []runtime.Object{pullSecret})
+			if got := namespacedNames(t, objects); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("addImagePullSecrets() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetPostgresImages(t *testing.T) {
+	tests := []struct {
+		name   string
+		global *hubofhubsv1alpha1.GlobalConfig
+		want   [3]string
+	}{
+		{
+			name: "crunchy registry by default",
+			want: [3]string{crunchyImageRegistry + postgresImage, crunchyImageRegistry + pgBackRestImage,
+				crunchyImageRegistry + pgBouncerImage},
+		},
+		{
+			name:   "crunchy registry for the default image registry",
+			global: &hubofhubsv1alpha1.GlobalConfig{ImageRegistry: defaultImageRegistry, ImageTag: "v0.4.0"},
+			want: [3]string{crunchyImageRegistry + postgresImage, crunchyImageRegistry + pgBackRestImage,
+				crunchyImageRegistry + pgBouncerImage},
+		},
+		{
+			name:   "mirror registry",
+			global: &hubofhubsv1alpha1.GlobalConfig{ImageRegistry: "mirror.example.com/hoh", ImageTag: "v0.4.0"},
+			want: [3]string{
+				"mirror.example.com/hoh/crunchy-postgres:centos8-13.4-1",
+				"mirror.example.com/hoh/crunchy-pgbackrest:centos8-2.35-0",
+				"mirror.example.com/hoh/crunchy-pgbouncer:centos8-1.15-3",
+			},
+		},
+		{
+			name: "image overrides",
+			global: &hubofhubsv1alpha1.GlobalConfig{
+				ImageRegistry:  "mirror.example.com/hoh",
+				ImageOverrides: &hubofhubsv1alpha1.ImageOverridesConfig{Postgres: "example.com/postgres:14"},
+			},
+			want: [3]string{
+				"example.com/postgres:14",
+				"mirror.example.com/hoh/crunchy-pgbackrest:centos8-2.35-0",
+				"mirror.example.com/hoh/crunchy-pgbouncer:centos8-1.15-3",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hohConfig := newTestConfig()
+			hohConfig.Spec.Global = tt.global
+			componentImages := getImages(hohConfig)
+			got := [3]string{componentImages.Postgres, componentImages.PgBackRest, componentImages.PgBouncer}
+			if got != tt.want {
+				t.Errorf("getImages() returned %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hubofhubs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	hubofhubsv1alpha1 "github.com/stolostron/hub-of-hubs-operator/apis/hubofhubs/v1alpha1"
+	"github.com/stolostron/hub-of-hubs-operator/pkg/deployer"
+)
+
+// objectRef identifies an object deployed by the operator in the inventory
+type objectRef struct {
+	APIVersion  string `json:"apiVersion"`
+	Kind        string `json:"kind"`
+	Namespace   string `json:"namespace,omitempty"`
+	Name        string `json:"name"`
+	DataBearing bool   `json:"dataBearing,omitempty"`
+}
+
+// inventory records the objects deployed for each hub component, keyed by the component name,
+// so that the objects that are no longer rendered can be deleted
+type inventory map[string][]objectRef
+
+// newObjectRef returns the reference of the given object
+func newObjectRef(obj runtime.Object) (objectRef, error) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return objectRef{}, err
+	}
+	apiVersion, kind := obj.GetObjectKind().GroupVersionKind().ToAPIVersionAndKind()
+	return objectRef{
+		APIVersion:  apiVersion,
+		Kind:        kind,
+		Namespace:   accessor.GetNamespace(),
+		Name:        accessor.GetName(),
+		DataBearing: isDataBearing(obj),
+	}, nil
+}
+
+// newObjectRefs returns the references of the given objects in the same order
+func newObjectRefs(objects []runtime.Object) ([]objectRef, error) {
+	refs := make([]objectRef, 0, len(objects))
+	for _, obj := range objects {
+		ref, err := newObjectRef(obj)
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, ref)
+	}
+	return refs, nil
+}
+
+// key identifies the referenced object regardless of the version of its kind
+func (ref objectRef) key() string {
+	groupKind := schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind).GroupKind()
+	return fmt.Sprintf("%s/%s/%s", groupKind.String(), ref.Namespace, ref.Name)
+}
+
+// toObject returns an object with only the metadata of the referenced object, which is enough to delete it
+func (ref objectRef) toObject() *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(ref.APIVersion)
+	obj.SetKind(ref.Kind)
+	obj.SetNamespace(ref.Namespace)
+	obj.SetName(ref.Name)
+	if ref.DataBearing {
+		obj.SetAnnotations(map[string]string{dataBearingAnnotationKey: "true"})
+	}
+	return obj
+}
+
+// subtractObjectRefs returns the references of from that are not in refs
+func subtractObjectRefs(from, refs []objectRef) []objectRef {
+	keys := make(map[string]bool, len(refs))
+	for _, ref := range refs {
+		keys[ref.key()] = true
+	}
+
+	var result []objectRef
+	for _, ref := range from {
+		if !keys[ref.key()] {
+			result = append(result, ref)
+		}
+	}
+	return result
+}
+
+// inventoryName returns the name of the ConfigMap holding the inventory of the given Config
+func inventoryName(hohConfig *hubofhubsv1alpha1.Config) string {
+	return hohConfig.GetName() + "-inventory"
+}
+
+// getInventory reads the inventory of the given Config, it is empty if nothing was deployed yet
+func (r *ConfigReconciler) getInventory(ctx context.Context, hohConfig *hubofhubsv1alpha1.Config,
+) (inventory, error) {
+	inv := inventory{}
+
+	configMap := &corev1.ConfigMap{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: hohConfig.GetNamespace(), Name: inventoryName(hohConfig)},
+		configMap); err != nil {
+		if errors.IsNotFound(err) {
+			return inv, nil
+		}
+		return nil, err
+	}
+
+	for component, data := range configMap.Data {
+		var refs []objectRef
+		if err := json.Unmarshal([]byte(data), &refs); err != nil {
+			return nil, fmt.Errorf("failed to parse the inventory of the %s component: %w", component, err)
+		}
+		inv[component] = refs
+	}
+
+	return inv, nil
+}
+
+// saveInventory writes the inventory of the given Config to a ConfigMap owned by the Config,
+// so that it is garbage collected with the Config
+func (r *ConfigReconciler) saveInventory(ctx context.Context, hohConfig *hubofhubsv1alpha1.Config,
+	inv inventory,
+) error {
+	data := make(map[string]string, len(inv))
+	for component, refs := range inv {
+		raw, err := json.Marshal(refs)
+		if err != nil {
+			return err
+		}
+		data[component] = string(raw)
+	}
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      inventoryName(hohConfig),
+			Namespace: hohConfig.GetNamespace(),
+		},
+	}
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, configMap, func() error {
+		configMap.Data = data
+		return controllerutil.SetControllerReference(hohConfig, configMap, r.Scheme)
+	})
+
+	return err
+}
+
+// recordComponent records the deployed objects of a component in the inventory and deletes the objects
+// deployed before that are no longer rendered by any component. The data-bearing objects are kept unless
+// the deletion policy is Delete, and the objects that are still being deleted stay in the inventory until
+// they are gone.
+func (r *ConfigReconciler) recordComponent(ctx context.Context, hohConfig *hubofhubsv1alpha1.Config,
+	hohDeployer deployer.Deployer, inv inventory, component string, objects []runtime.Object,
+	renderedRefs []objectRef,
+) error {
+	log := ctrllog.FromContext(ctx)
+
+	refs, err := newObjectRefs(objects)
+	if err != nil {
+		return err
+	}
+
+	stale := subtractObjectRefs(inv[component], renderedRefs)
+	var pending []objectRef
+	var pruneErr error
+	for i := len(stale) - 1; i >= 0; i-- {
+		ref := stale[i]
+		if ref.DataBearing && hohConfig.Spec.DeletionPolicy != hubofhubsv1alpha1.DeleteDeletionPolicy {
+			log.Info("Retaining data-bearing object that is no longer rendered", "object", ref)
+			continue
+		}
+
+		obj := ref.toObject()
+		if err := setOwner(obj, hohConfig); err != nil {
+			return err
+		}
+		log.Info("Deleting object that is no longer rendered", "object", ref)
+		deleted, err := hohDeployer.Undeploy(obj)
+		if err != nil {
+			pruneErr = fmt.Errorf("failed to delete %s %s/%s: %w", ref.Kind, ref.Namespace, ref.Name, err)
+		}
+		if err != nil || !deleted {
+			pending = append([]objectRef{ref}, pending...)
+		}
+	}
+
+	inv[component] = append(refs, pending...)
+	if err := r.saveInventory(ctx, hohConfig, inv); err != nil {
+		return err
+	}
+
+	return pruneErr
+}
+
+// recordFailedComponent adds the rendered objects of a component that failed to deploy to the inventory,
+// some of them may have been deployed and have to be tracked to be pruned later
+func (r *ConfigReconciler) recordFailedComponent(ctx context.Context, hohConfig *hubofhubsv1alpha1.Config,
+	inv inventory, component string, objects []runtime.Object,
+) error {
+	refs, err := newObjectRefs(objects)
+	if err != nil {
+		return err
+	}
+
+	inv[component] = append(inv[component], subtractObjectRefs(refs, inv[component])...)
+	return r.saveInventory(ctx, hohConfig, inv)
+}
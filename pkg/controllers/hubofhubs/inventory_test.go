@@ -0,0 +1,169 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hubofhubs
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	hubofhubsv1alpha1 "github.com/stolostron/hub-of-hubs-operator/apis/hubofhubs/v1alpha1"
+)
+
+// fakeDeployer records the objects that are undeployed, the objects in pending are still being deleted
+type fakeDeployer struct {
+	undeployed []string
+	pending    map[string]bool
+}
+
+func (d *fakeDeployer) Deploy(obj runtime.Object) error {
+	return nil
+}
+
+func (d *fakeDeployer) Undeploy(obj runtime.Object) (bool, error) {
+	ref, err := newObjectRef(obj)
+	if err != nil {
+		return false, err
+	}
+	d.undeployed = append(d.undeployed, ref.Name)
+	return !d.pending[ref.Name], nil
+}
+
+// testObject returns a ConfigMap with the given name, annotated as data-bearing if requested
+func testObject(name string, dataBearing bool) runtime.Object {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("v1")
+	obj.SetKind("ConfigMap")
+	obj.SetNamespace("hoh")
+	obj.SetName(name)
+	if dataBearing {
+		obj.SetAnnotations(map[string]string{dataBearingAnnotationKey: "true"})
+	}
+	return obj
+}
+
+func testRefs(t *testing.T, objects ...runtime.Object) []objectRef {
+	t.Helper()
+	refs, err := newObjectRefs(objects)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return refs
+}
+
+func refNames(refs []objectRef) []string {
+	names := []string{}
+	for _, ref := range refs {
+		names = append(names, ref.Name)
+	}
+	return names
+}
+
+func TestSubtractObjectRefs(t *testing.T) {
+	from := testRefs(t, testObject("a", false), testObject("b", false), testObject("c", true))
+	refs := testRefs(t, testObject("b", false))
+	// the version of the kind doesn't identify the object
+	refs = append(refs, objectRef{APIVersion: "v2", Kind: "ConfigMap", Namespace: "hoh", Name: "c"})
+
+	if got, want := refNames(subtractObjectRefs(from, refs)), []string{"a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("subtractObjectRefs() = %v, want %v", got, want)
+	}
+}
+
+func TestRecordComponent(t *testing.T) {
+	tests := []struct {
+		name           string
+		deletionPolicy hubofhubsv1alpha1.DeletionPolicy
+		recorded       []runtime.Object
+		rendered       []runtime.Object
+		// renderedByOthers are rendered by the other components
+		renderedByOthers []runtime.Object
+		pending          map[string]bool
+		wantUndeployed   []string
+		wantInventory    []string
+	}{
+		{
+			name:           "stale objects are pruned",
+			recorded:       []runtime.Object{testObject("a", false), testObject("b", false), testObject("c", false)},
+			rendered:       []runtime.Object{testObject("a", false)},
+			wantUndeployed: []string{"c", "b"},
+			wantInventory:  []string{"a"},
+		},
+		{
+			name:             "objects rendered by another component are kept",
+			recorded:         []runtime.Object{testObject("a", false), testObject("moved", false)},
+			rendered:         []runtime.Object{testObject("a", false)},
+			renderedByOthers: []runtime.Object{testObject("moved", false)},
+			wantUndeployed:   []string{},
+			wantInventory:    []string{"a"},
+		},
+		{
+			name:           "data-bearing objects are retained",
+			deletionPolicy: hubofhubsv1alpha1.RetainDeletionPolicy,
+			recorded:       []runtime.Object{testObject("a", false), testObject("data", true)},
+			rendered:       []runtime.Object{testObject("a", false)},
+			wantUndeployed: []string{},
+			wantInventory:  []string{"a"},
+		},
+		{
+			name:           "data-bearing objects are deleted with the Delete policy",
+			deletionPolicy: hubofhubsv1alpha1.DeleteDeletionPolicy,
+			recorded:       []runtime.Object{testObject("a", false), testObject("data", true)},
+			rendered:       []runtime.Object{testObject("a", false)},
+			wantUndeployed: []string{"data"},
+			wantInventory:  []string{"a"},
+		},
+		{
+			name:           "objects being deleted stay in the inventory",
+			recorded:       []runtime.Object{testObject("a", false), testObject("b", false)},
+			rendered:       []runtime.Object{testObject("a", false)},
+			pending:        map[string]bool{"b": true},
+			wantUndeployed: []string{"b"},
+			wantInventory:  []string{"a", "b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.TODO()
+			hohConfig := newTestConfig()
+			hohConfig.Spec.DeletionPolicy = tt.deletionPolicy
+			r := newTestReconciler(t, hohConfig)
+			hohDeployer := &fakeDeployer{undeployed: []string{}, pending: tt.pending}
+
+			inv := inventory{"component": testRefs(t, tt.recorded...)}
+			renderedRefs := append(testRefs(t, tt.rendered...), testRefs(t, tt.renderedByOthers...)...)
+			if err := r.recordComponent(ctx, hohConfig, hohDeployer, inv, "component", tt.rendered,
+				renderedRefs); err != nil {
+				t.Fatalf("recordComponent() failed: %v", err)
+			}
+
+			if !reflect.DeepEqual(hohDeployer.undeployed, tt.wantUndeployed) {
+				t.Errorf("undeployed %v, want %v", hohDeployer.undeployed, tt.wantUndeployed)
+			}
+			saved, err := r.getInventory(ctx, hohConfig)
+			if err != nil {
+				t.Fatalf("getInventory() failed: %v", err)
+			}
+			if got := refNames(saved["component"]); !reflect.DeepEqual(got, tt.wantInventory) {
+				t.Errorf("recorded %v, want %v", got, tt.wantInventory)
+			}
+		})
+	}
+}
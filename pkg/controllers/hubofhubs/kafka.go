@@ -0,0 +1,477 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hubofhubs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/scram"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+
+	hubofhubsv1alpha1 "github.com/stolostron/hub-of-hubs-operator/apis/hubofhubs/v1alpha1"
+)
+
+const (
+	kafkaNamespace           = "kafka"
+	kafkaClusterName         = "kafka-brokers-cluster"
+	kafkaClusterCASecretName = kafkaClusterName + "-cluster-ca-cert"
+	kafkaExternalListener    = "external"
+	defaultKafkaVersion      = "2.7.0"
+	defaultKafkaReplicas     = 3
+	// kafkaDialTimeout is the timeout of the connection to an external kafka cluster
+	kafkaDialTimeout = 10 * time.Second
+	// maxKafkaReplicationFactor is the replication factor of the topics when there are enough brokers
+	maxKafkaReplicationFactor = 3
+)
+
+// kafkaTopics are the topics used by the hub-of-hubs components, they must exist in an external kafka cluster
+var kafkaTopics = hubofhubsv1alpha1.RequiredKafkaTopics
+
+// kafkaConnection contains the connection details of the kafka cluster passed to the manager and the agents
+type kafkaConnection struct {
+	BootstrapServer string
+	// CA is the base64 encoded CA certificate of the brokers
+	CA string
+	// AuthenticationType is empty for anonymous clients
+	AuthenticationType string
+	ClientCert         []byte
+	ClientKey          []byte
+	Username           []byte
+	Password           []byte
+
+	caCert []byte
+	useTLS bool
+}
+
+// kafkaClusterConfig contains the settings of the kafka cluster deployed with strimzi
+type kafkaClusterConfig struct {
+	Version string
+	// ProtocolVersion is the major and minor version of Kafka used by the brokers to communicate
+	ProtocolVersion string
+	// LogMessageFormatVersion is empty for Kafka 3 and later, which ignore it
+	LogMessageFormatVersion string
+	Replicas                uint64
+	ZooKeeperReplicas       uint64
+	// ReplicationFactor and MinInSyncReplicas are derived from the number of brokers
+	ReplicationFactor uint64
+	MinInSyncReplicas uint64
+	// StorageSize is empty for ephemeral storage
+	StorageSize      string
+	StorageClassName string
+	ResourceRequests map[string]string
+	ResourceLimits   map[string]string
+	Topics           []kafkaTopic
+}
+
+// kafkaTopic contains the settings of a KafkaTopic
+type kafkaTopic struct {
+	Name          string
+	Partitions    uint64
+	Replicas      uint64
+	CleanupPolicy string
+	// RetentionMs is empty when the retention of the broker is used
+	RetentionMs string
+}
+
+// getKafkaClusterConfig returns the settings of the kafka cluster deployed with strimzi, the replication
+// factor is the number of brokers up to 3, so that a single broker can be used for development
+func getKafkaClusterConfig(hohConfig *hubofhubsv1alpha1.Config) (kafkaClusterConfig, error) {
+	config := kafkaClusterConfig{
+		Version:           defaultKafkaVersion,
+		Replicas:          defaultKafkaReplicas,
+		ZooKeeperReplicas: defaultKafkaReplicas,
+	}
+
+	var kafkaConfig *hubofhubsv1alpha1.KafkaConfig
+	if components := hohConfig.Spec.Components; components != nil && components.Transport != nil {
+		kafkaConfig = components.Transport.Kafka
+	}
+	if kafkaConfig != nil {
+		if kafkaConfig.Version != "" {
+			config.Version = kafkaConfig.Version
+		}
+		if kafkaConfig.Replicas > 0 {
+			config.Replicas = kafkaConfig.Replicas
+		}
+		if kafkaConfig.ZooKeeperReplicas > 0 {
+			config.ZooKeeperReplicas = kafkaConfig.ZooKeeperReplicas
+		}
+		if storage := kafkaConfig.Storage; storage != nil {
+			config.StorageSize = storage.Size.String()
+			if storage.StorageClassName != nil {
+				config.StorageClassName = *storage.StorageClassName
+			}
+		}
+		if resources := kafkaConfig.Resources; resources != nil {
+			config.ResourceRequests = resourceListToStrings(resources.Requests)
+			config.ResourceLimits = resourceListToStrings(resources.Limits)
+		}
+	}
+
+	versionParts := strings.SplitN(config.Version, ".", 3)
+	if len(versionParts) < 2 {
+		return config, fmt.Errorf("invalid kafka version %s, the major and minor versions are required", config.Version)
+	}
+	config.ProtocolVersion = strings.Join(versionParts[:2], ".")
+	if major, err := strconv.Atoi(versionParts[0]); err == nil && major < 3 {
+		config.LogMessageFormatVersion = config.ProtocolVersion
+	}
+	config.ReplicationFactor = config.Replicas
+	if config.ReplicationFactor > maxKafkaReplicationFactor {
+		config.ReplicationFactor = maxKafkaReplicationFactor
+	}
+	config.MinInSyncReplicas = 1
+	if config.ReplicationFactor > 1 {
+		config.MinInSyncReplicas = config.ReplicationFactor - 1
+	}
+
+	for _, topicConfig := range kafkaConfig.GetTopics() {
+		topic := kafkaTopic{
+			Name:          topicConfig.Name,
+			Partitions:    topicConfig.Partitions,
+			Replicas:      topicConfig.Replicas,
+			CleanupPolicy: string(topicConfig.CleanupPolicy),
+		}
+		if topic.Replicas == 0 {
+			topic.Replicas = config.ReplicationFactor
+		}
+		if topicConfig.RetentionMs != nil {
+			topic.RetentionMs = strconv.FormatInt(*topicConfig.RetentionMs, 10)
+		}
+		config.Topics = append(config.Topics, topic)
+	}
+
+	return config, nil
+}
+
+// getExternalKafka returns the settings of the external kafka cluster, or nil if kafka is deployed by the operator
+func getExternalKafka(hohConfig *hubofhubsv1alpha1.Config) *hubofhubsv1alpha1.ExternalKafkaConfig {
+	if components := hohConfig.Spec.Components; components != nil && components.Transport != nil &&
+		components.Transport.Kafka != nil {
+		return components.Transport.Kafka.External
+	}
+	return nil
+}
+
+// getKafkaConnection returns the connection details of the external kafka cluster or of the external listener
+// of the kafka cluster deployed with strimzi
+func (r *ConfigReconciler) getKafkaConnection(ctx context.Context, hohConfig *hubofhubsv1alpha1.Config,
+) (*kafkaConnection, error) {
+	external := getExternalKafka(hohConfig)
+	if external == nil {
+		bootstrapServer, caCert, err := r.getKafkaBootstrapServerAndCA(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return &kafkaConnection{
+			BootstrapServer: bootstrapServer,
+			CA:              base64.StdEncoding.EncodeToString(caCert),
+			caCert:          caCert,
+			useTLS:          true,
+		}, nil
+	}
+
+	getSecret := func(name string) (*corev1.Secret, error) {
+		secret := &corev1.Secret{}
+		if err := r.Get(ctx, types.NamespacedName{Namespace: hohConfig.GetNamespace(), Name: name},
+			secret); err != nil {
+			return nil, fmt.Errorf("failed to get the kafka secret %s: %w", name, err)
+		}
+		return secret, nil
+	}
+
+	connection := &kafkaConnection{BootstrapServer: strings.Join(external.BootstrapServers, ",")}
+	if external.CASecret != nil {
+		caSecret, err := getSecret(external.CASecret.Name)
+		if err != nil {
+			return nil, err
+		}
+		connection.caCert = caSecret.Data["ca.crt"]
+		connection.CA = base64.StdEncoding.EncodeToString(connection.caCert)
+		connection.useTLS = true
+	}
+
+	authentication := external.Authentication
+	if authentication == nil {
+		return connection, nil
+	}
+	credentials, err := getSecret(authentication.Secret.Name)
+	if err != nil {
+		return nil, err
+	}
+	connection.AuthenticationType = string(authentication.Type)
+	if authentication.Type == hubofhubsv1alpha1.TLSKafkaAuthenticationType {
+		connection.ClientCert, connection.ClientKey = credentials.Data["tls.crt"], credentials.Data["tls.key"]
+		connection.useTLS = true
+		if len(connection.ClientCert) == 0 || len(connection.ClientKey) == 0 {
+			return nil, fmt.Errorf("the kafka secret %s must have the tls.crt and tls.key keys",
+				authentication.Secret.Name)
+		}
+	} else {
+		connection.Username, connection.Password = credentials.Data["username"], credentials.Data["password"]
+		if len(connection.Username) == 0 || len(connection.Password) == 0 {
+			return nil, fmt.Errorf("the kafka secret %s must have the username and password keys",
+				authentication.Secret.Name)
+		}
+	}
+
+	return connection, nil
+}
+
+// getKafkaBootstrapServerAndCA returns the bootstrap server of the external listener of the kafka cluster
+// deployed with strimzi and its CA certificate
+func (r *ConfigReconciler) getKafkaBootstrapServerAndCA(ctx context.Context) (string, []byte, error) {
+	kafkaCluster := &unstructured.Unstructured{}
+	kafkaCluster.SetAPIVersion("kafka.strimzi.io/v1beta2")
+	kafkaCluster.SetKind("Kafka")
+	if err := r.Get(ctx, types.NamespacedName{Namespace: kafkaNamespace, Name: kafkaClusterName},
+		kafkaCluster); err != nil {
+		return "", nil, err
+	}
+
+	listeners, _, err := unstructured.NestedSlice(kafkaCluster.Object, "status", "listeners")
+	if err != nil {
+		return "", nil, err
+	}
+	var bootstrapServer string
+	for _, listener := range listeners {
+		listenerMap, ok := listener.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		// the listener status is identified by name in recent strimzi versions and by type in older ones
+		name, _, _ := unstructured.NestedString(listenerMap, "name")
+		listenerType, _, _ := unstructured.NestedString(listenerMap, "type")
+		if name == kafkaExternalListener || listenerType == kafkaExternalListener {
+			bootstrapServer, _, _ = unstructured.NestedString(listenerMap, "bootstrapServers")
+			break
+		}
+	}
+	if bootstrapServer == "" {
+		return "", nil, fmt.Errorf("the bootstrap server of the %s listener of kafka is not available yet",
+			kafkaExternalListener)
+	}
+
+	caSecret := &corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: kafkaNamespace, Name: kafkaClusterCASecretName},
+		caSecret); err != nil {
+		return "", nil, err
+	}
+
+	return bootstrapServer, caSecret.Data["ca.crt"], nil
+}
+
+// kafkaTopicsReady checks that the KafkaTopics are ready and records the readiness of each of them
+// in the Config status
+func (r *ConfigReconciler) kafkaTopicsReady(ctx context.Context, hohConfig *hubofhubsv1alpha1.Config,
+) (bool, string, error) {
+	previousConditions := map[string][]metav1.Condition{}
+	for _, topicStatus := range hohConfig.Status.KafkaTopics {
+		previousConditions[topicStatus.Name] = topicStatus.Conditions
+	}
+
+	kafkaConfig, err := getKafkaClusterConfig(hohConfig)
+	if err != nil {
+		return false, "", err
+	}
+
+	var topicStatuses []hubofhubsv1alpha1.KafkaTopicStatus
+	var notReadyTopics []string
+	for _, topic := range kafkaConfig.Topics {
+		status, reason, message, err := r.kafkaTopicReadiness(ctx, topic)
+		if err != nil {
+			return false, "", err
+		}
+		if status != metav1.ConditionTrue {
+			notReadyTopics = append(notReadyTopics, topic.Name)
+		}
+
+		conditions := previousConditions[topic.Name]
+		meta.SetStatusCondition(&conditions, metav1.Condition{
+			Type:               hubofhubsv1alpha1.ConditionTypeReady,
+			Status:             status,
+			Reason:             reason,
+			Message:            message,
+			ObservedGeneration: hohConfig.GetGeneration(),
+		})
+		topicStatuses = append(topicStatuses, hubofhubsv1alpha1.KafkaTopicStatus{
+			Name:       topic.Name,
+			Conditions: conditions,
+		})
+	}
+	hohConfig.Status.KafkaTopics = topicStatuses
+
+	if len(notReadyTopics) > 0 {
+		return false, fmt.Sprintf("waiting for the kafka topics %s to be ready",
+			strings.Join(notReadyTopics, ", ")), nil
+	}
+	return true, "", nil
+}
+
+// kafkaTopicReadiness returns the status, reason and message of the Ready condition of a topic, from the
+// Ready condition that the strimzi topic operator sets once it applied the latest spec of the KafkaTopic
+func (r *ConfigReconciler) kafkaTopicReadiness(ctx context.Context, topic kafkaTopic,
+) (metav1.ConditionStatus, string, string, error) {
+	kafkaTopic := &unstructured.Unstructured{}
+	kafkaTopic.SetAPIVersion("kafka.strimzi.io/v1beta2")
+	kafkaTopic.SetKind("KafkaTopic")
+	if err := r.Get(ctx, types.NamespacedName{Namespace: kafkaNamespace, Name: topic.Name},
+		kafkaTopic); err != nil {
+		if errors.IsNotFound(err) {
+			return metav1.ConditionUnknown, reasonNotReady, "the KafkaTopic is not created yet", nil
+		}
+		return "", "", "", err
+	}
+
+	observedGeneration, _, _ := unstructured.NestedInt64(kafkaTopic.Object, "status", "observedGeneration")
+	if observedGeneration < kafkaTopic.GetGeneration() {
+		return metav1.ConditionUnknown, reasonNotReady,
+			"waiting for the topic operator to apply the KafkaTopic", nil
+	}
+
+	conditions, _, err := unstructured.NestedSlice(kafkaTopic.Object, "status", "conditions")
+	if err != nil {
+		return "", "", "", err
+	}
+	for _, condition := range conditions {
+		conditionMap, ok := condition.(map[string]interface{})
+		if !ok || conditionMap["type"] != "Ready" {
+			continue
+		}
+		message, _, _ := unstructured.NestedString(conditionMap, "message")
+		switch conditionMap["status"] {
+		case "True":
+			return metav1.ConditionTrue, reasonReady, fmt.Sprintf("the topic has %d partitions",
+				topic.Partitions), nil
+		case "False":
+			return metav1.ConditionFalse, reasonNotReady, message, nil
+		}
+	}
+
+	return metav1.ConditionUnknown, reasonNotReady, "waiting for the topic operator to report the KafkaTopic", nil
+}
+
+// externalKafkaReady checks that the external kafka cluster is reachable and has the hub-of-hubs topics
+func (r *ConfigReconciler) externalKafkaReady(ctx context.Context, hohConfig *hubofhubsv1alpha1.Config,
+) (bool, string, error) {
+	connection, err := r.getKafkaConnection(ctx, hohConfig)
+	if err != nil {
+		return false, "", err
+	}
+
+	missingTopics, err := connection.missingTopics(ctx, kafkaTopics)
+	if err != nil {
+		return false, "", err
+	}
+	if len(missingTopics) > 0 {
+		return false, fmt.Sprintf("waiting for the kafka topics %s to be created",
+			strings.Join(missingTopics, ", ")), nil
+	}
+
+	return true, "", nil
+}
+
+// missingTopics connects to the kafka cluster and returns the given topics that don't exist
+func (c *kafkaConnection) missingTopics(ctx context.Context, topics []string) ([]string, error) {
+	dialer, err := c.dialer()
+	if err != nil {
+		return nil, err
+	}
+
+	var conn *kafka.Conn
+	var errs []error
+	for _, server := range strings.Split(c.BootstrapServer, ",") {
+		if conn, err = dialer.DialContext(ctx, "tcp", server); err == nil {
+			break
+		}
+		errs = append(errs, err)
+	}
+	if conn == nil {
+		return nil, fmt.Errorf("failed to connect to kafka: %w", utilerrors.NewAggregate(errs))
+	}
+	defer conn.Close()
+
+	partitions, err := conn.ReadPartitions()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the kafka topics: %w", err)
+	}
+	existingTopics := map[string]bool{}
+	for _, partition := range partitions {
+		existingTopics[partition.Topic] = true
+	}
+
+	var missing []string
+	for _, topic := range topics {
+		if !existingTopics[topic] {
+			missing = append(missing, topic)
+		}
+	}
+	return missing, nil
+}
+
+// dialer returns a kafka dialer with the TLS and SASL settings of the connection
+func (c *kafkaConnection) dialer() (*kafka.Dialer, error) {
+	dialer := &kafka.Dialer{Timeout: kafkaDialTimeout, DualStack: true}
+
+	if c.useTLS {
+		tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+		if len(c.caCert) > 0 {
+			tlsConfig.RootCAs = x509.NewCertPool()
+			if !tlsConfig.RootCAs.AppendCertsFromPEM(c.caCert) {
+				return nil, fmt.Errorf("failed to parse the CA certificate of kafka")
+			}
+		}
+		if len(c.ClientCert) > 0 {
+			certificate, err := tls.X509KeyPair(c.ClientCert, c.ClientKey)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse the client certificate of kafka: %w", err)
+			}
+			tlsConfig.Certificates = []tls.Certificate{certificate}
+		}
+		dialer.TLS = tlsConfig
+	}
+
+	var mechanism sasl.Mechanism
+	var err error
+	switch hubofhubsv1alpha1.KafkaAuthenticationType(c.AuthenticationType) {
+	case hubofhubsv1alpha1.ScramSha256KafkaAuthenticationType:
+		mechanism, err = scram.Mechanism(scram.SHA256, string(c.Username), string(c.Password))
+	case hubofhubsv1alpha1.ScramSha512KafkaAuthenticationType:
+		mechanism, err = scram.Mechanism(scram.SHA512, string(c.Username), string(c.Password))
+	}
+	if err != nil {
+		return nil, err
+	}
+	dialer.SASLMechanism = mechanism
+
+	return dialer, nil
+}
@@ -0,0 +1,324 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hubofhubs
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	hubofhubsv1alpha1 "github.com/stolostron/hub-of-hubs-operator/apis/hubofhubs/v1alpha1"
+	"github.com/stolostron/hub-of-hubs-operator/pkg/renderer"
+)
+
+func TestGetKafkaClusterConfig(t *testing.T) {
+	tests := []struct {
+		name                        string
+		kafka                       *hubofhubsv1alpha1.KafkaConfig
+		wantProtocolVersion         string
+		wantLogMessageFormatVersion string
+		wantReplicationFactor       uint64
+		wantMinInSyncReplicas       uint64
+		wantErr                     bool
+	}{
+		{
+			name:                        "defaults",
+			wantProtocolVersion:         "2.7",
+			wantLogMessageFormatVersion: "2.7",
+			wantReplicationFactor:       3,
+			wantMinInSyncReplicas:       2,
+		},
+		{
+			name:                  "single broker",
+			kafka:                 &hubofhubsv1alpha1.KafkaConfig{Version: "3.1.0", Replicas: 1},
+			wantProtocolVersion:   "3.1",
+			wantReplicationFactor: 1,
+			wantMinInSyncReplicas: 1,
+		},
+		{
+			name:                  "two brokers",
+			kafka:                 &hubofhubsv1alpha1.KafkaConfig{Version: "3.1.0", Replicas: 2},
+			wantProtocolVersion:   "3.1",
+			wantReplicationFactor: 2,
+			wantMinInSyncReplicas: 1,
+		},
+		{
+			name:                        "more brokers than the replication factor",
+			kafka:                       &hubofhubsv1alpha1.KafkaConfig{Version: "2.8.1", Replicas: 5},
+			wantProtocolVersion:         "2.8",
+			wantLogMessageFormatVersion: "2.8",
+			wantReplicationFactor:       3,
+			wantMinInSyncReplicas:       2,
+		},
+		{
+			name:    "version without a minor version",
+			kafka:   &hubofhubsv1alpha1.KafkaConfig{Version: "3"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hohConfig := newTestConfig()
+			if tt.kafka != nil {
+				hohConfig.Spec.Components = &hubofhubsv1alpha1.ComponentsConfig{
+					Transport: &hubofhubsv1alpha1.TransportConfig{Kafka: tt.kafka},
+				}
+			}
+
+			config, err := getKafkaClusterConfig(hohConfig)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getKafkaClusterConfig() returned %v, want an error: %t", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if config.ProtocolVersion != tt.wantProtocolVersion {
+				t.Errorf("the protocol version is %q, want %q", config.ProtocolVersion, tt.wantProtocolVersion)
+			}
+			if config.LogMessageFormatVersion != tt.wantLogMessageFormatVersion {
+				t.Errorf("the log message format version is %q, want %q", config.LogMessageFormatVersion,
+					tt.wantLogMessageFormatVersion)
+			}
+			if config.ReplicationFactor != tt.wantReplicationFactor {
+				t.Errorf("the replication factor is %d, want %d", config.ReplicationFactor, tt.wantReplicationFactor)
+			}
+			if config.MinInSyncReplicas != tt.wantMinInSyncReplicas {
+				t.Errorf("the min in-sync replicas are %d, want %d", config.MinInSyncReplicas,
+					tt.wantMinInSyncReplicas)
+			}
+		})
+	}
+}
+
+func TestExternalKafka(t *testing.T) {
+	caSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "open-cluster-management", Name: "kafka-ca"},
+		Data:       map[string][]byte{"ca.crt": []byte("ca")},
+	}
+	tlsSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "open-cluster-management", Name: "kafka-tls"},
+		Data:       map[string][]byte{"tls.crt": []byte("cert"), "tls.key": []byte("key")},
+	}
+	scramSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "open-cluster-management", Name: "kafka-scram"},
+		Data:       map[string][]byte{"username": []byte("user"), "password": []byte("password")},
+	}
+
+	tests := []struct {
+		name           string
+		caSecret       string
+		authentication *hubofhubsv1alpha1.KafkaAuthenticationConfig
+		want           *kafkaConnection
+	}{
+		{
+			name: "anonymous",
+			want: &kafkaConnection{BootstrapServer: "kafka-0.example.com:9092,kafka-1.example.com:9092"},
+		},
+		{
+			name:     "ca",
+			caSecret: "kafka-ca",
+			want: &kafkaConnection{
+				BootstrapServer: "kafka-0.example.com:9092,kafka-1.example.com:9092",
+				CA:              "Y2E=",
+				caCert:          []byte("ca"),
+				useTLS:          true,
+			},
+		},
+		{
+			name: "tls",
+			authentication: &hubofhubsv1alpha1.KafkaAuthenticationConfig{
+				Type:   hubofhubsv1alpha1.TLSKafkaAuthenticationType,
+				Secret: corev1.LocalObjectReference{Name: "kafka-tls"},
+			},
+			want: &kafkaConnection{
+				BootstrapServer:    "kafka-0.example.com:9092,kafka-1.example.com:9092",
+				AuthenticationType: string(hubofhubsv1alpha1.TLSKafkaAuthenticationType),
+				ClientCert:         []byte("cert"),
+				ClientKey:          []byte("key"),
+				useTLS:             true,
+			},
+		},
+		{
+			name: "scram",
+			authentication: &hubofhubsv1alpha1.KafkaAuthenticationConfig{
+				Type:   hubofhubsv1alpha1.ScramSha512KafkaAuthenticationType,
+				Secret: corev1.LocalObjectReference{Name: "kafka-scram"},
+			},
+			want: &kafkaConnection{
+				BootstrapServer:    "kafka-0.example.com:9092,kafka-1.example.com:9092",
+				AuthenticationType: string(hubofhubsv1alpha1.ScramSha512KafkaAuthenticationType),
+				Username:           []byte("user"),
+				Password:           []byte("password"),
+			},
+		},
+		{
+			name: "tls without a client certificate",
+			authentication: &hubofhubsv1alpha1.KafkaAuthenticationConfig{
+				Type:   hubofhubsv1alpha1.TLSKafkaAuthenticationType,
+				Secret: corev1.LocalObjectReference{Name: "kafka-scram"},
+			},
+		},
+		{
+			name:     "missing ca secret",
+			caSecret: "missing",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.TODO()
+			hohConfig := newTestConfig()
+			external := &hubofhubsv1alpha1.ExternalKafkaConfig{
+				BootstrapServers: []string{"kafka-0.example.com:9092", "kafka-1.example.com:9092"},
+				Authentication:   tt.authentication,
+			}
+			if tt.caSecret != "" {
+				external.CASecret = &corev1.LocalObjectReference{Name: tt.caSecret}
+			}
+			hohConfig.Spec.Components = &hubofhubsv1alpha1.ComponentsConfig{
+				Transport: &hubofhubsv1alpha1.TransportConfig{
+					Kafka: &hubofhubsv1alpha1.KafkaConfig{External: external},
+				},
+			}
+			r := newTestReconciler(t, caSecret, tlsSecret, scramSecret)
+
+			// the kafka cluster isn't deployed for an external kafka
+			objects, err := r.renderTransport(ctx, hohConfig, renderer.NewHoHRenderer(fs))
+			if err != nil {
+				t.Fatalf("renderTransport() failed: %v", err)
+			}
+			if len(objects) != 0 {
+				t.Errorf("renderTransport() rendered %v, want nothing", kindsAndNames(t, objects))
+			}
+
+			connection, err := r.getKafkaConnection(ctx, hohConfig)
+			if (err != nil) != (tt.want == nil) {
+				t.Fatalf("getKafkaConnection() returned %v, want an error: %t", err, tt.want == nil)
+			}
+			if !reflect.DeepEqual(connection, tt.want) {
+				t.Errorf("getKafkaConnection() = %+v, want %+v", connection, tt.want)
+			}
+		})
+	}
+}
+
+func TestExternalKafkaReady(t *testing.T) {
+	hohConfig := newTestConfig()
+	hohConfig.Spec.Components = &hubofhubsv1alpha1.ComponentsConfig{
+		Transport: &hubofhubsv1alpha1.TransportConfig{
+			Kafka: &hubofhubsv1alpha1.KafkaConfig{
+				// nothing listens on the port, so the connection is refused
+				External: &hubofhubsv1alpha1.ExternalKafkaConfig{BootstrapServers: []string{"127.0.0.1:1"}},
+			},
+		},
+	}
+	r := newTestReconciler(t)
+
+	ready, _, err := r.transportReady(context.TODO(), hohConfig)
+	if ready || err == nil {
+		t.Errorf("transportReady() returned %t, %v, want an error", ready, err)
+	}
+	if hohConfig.Status.KafkaTopics != nil {
+		t.Errorf("the status of the topics of an external kafka is %v, want none", hohConfig.Status.KafkaTopics)
+	}
+}
+
+// testKafkaTopic returns a KafkaTopic with the given generation, observed generation and Ready condition
+func testKafkaTopic(name string, generation, observedGeneration int64, ready string) *unstructured.Unstructured {
+	topic := &unstructured.Unstructured{Object: map[string]interface{}{
+		"status": map[string]interface{}{
+			"observedGeneration": observedGeneration,
+			"conditions": []interface{}{
+				map[string]interface{}{"type": "Ready", "status": ready, "message": "the topic is " + name},
+			},
+		},
+	}}
+	topic.SetAPIVersion("kafka.strimzi.io/v1beta2")
+	topic.SetKind("KafkaTopic")
+	topic.SetNamespace(kafkaNamespace)
+	topic.SetName(name)
+	topic.SetGeneration(generation)
+	return topic
+}
+
+func TestKafkaTopicsReady(t *testing.T) {
+	tests := []struct {
+		name         string
+		topics       []runtime.Object
+		wantReady    bool
+		wantStatuses map[string]metav1.ConditionStatus
+	}{
+		{
+			name:         "ready topics",
+			topics:       []runtime.Object{testKafkaTopic("spec", 1, 1, "True"), testKafkaTopic("status", 1, 1, "True")},
+			wantReady:    true,
+			wantStatuses: map[string]metav1.ConditionStatus{"spec": metav1.ConditionTrue, "status": metav1.ConditionTrue},
+		},
+		{
+			name:   "missing topic",
+			topics: []runtime.Object{testKafkaTopic("spec", 1, 1, "True")},
+			wantStatuses: map[string]metav1.ConditionStatus{
+				"spec": metav1.ConditionTrue, "status": metav1.ConditionUnknown,
+			},
+		},
+		{
+			name:   "topic not applied yet",
+			topics: []runtime.Object{testKafkaTopic("spec", 2, 1, "True"), testKafkaTopic("status", 1, 1, "True")},
+			wantStatuses: map[string]metav1.ConditionStatus{
+				"spec": metav1.ConditionUnknown, "status": metav1.ConditionTrue,
+			},
+		},
+		{
+			name:   "failed topic",
+			topics: []runtime.Object{testKafkaTopic("spec", 1, 1, "True"), testKafkaTopic("status", 1, 1, "False")},
+			wantStatuses: map[string]metav1.ConditionStatus{
+				"spec": metav1.ConditionTrue, "status": metav1.ConditionFalse,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hohConfig := newTestConfig()
+			r := newTestReconciler(t, tt.topics...)
+
+			ready, message, err := r.kafkaTopicsReady(context.TODO(), hohConfig)
+			if err != nil {
+				t.Fatalf("kafkaTopicsReady() failed: %v", err)
+			}
+			if ready != tt.wantReady || (message == "") != tt.wantReady {
+				t.Errorf("kafkaTopicsReady() returned %t, %q, want ready: %t", ready, message, tt.wantReady)
+			}
+
+			statuses := map[string]metav1.ConditionStatus{}
+			for _, topicStatus := range hohConfig.Status.KafkaTopics {
+				condition := meta.FindStatusCondition(topicStatus.Conditions, hubofhubsv1alpha1.ConditionTypeReady)
+				if condition == nil {
+					t.Fatalf("the topic %s has no Ready condition", topicStatus.Name)
+				}
+				statuses[topicStatus.Name] = condition.Status
+			}
+			if !reflect.DeepEqual(statuses, tt.wantStatuses) {
+				t.Errorf("the topic statuses are %v, want %v", statuses, tt.wantStatuses)
+			}
+		})
+	}
+}
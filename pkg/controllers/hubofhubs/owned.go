@@ -0,0 +1,154 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hubofhubs
+
+import (
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	cdpov1beta1 "github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+
+	hubofhubsv1alpha1 "github.com/stolostron/hub-of-hubs-operator/apis/hubofhubs/v1alpha1"
+)
+
+const (
+	// managedByLabelKey is the label that tracks the objects deployed by the operator
+	managedByLabelKey   = "hubofhubs.open-cluster-management.io/managed-by"
+	managedByLabelValue = "hub-of-hubs-operator"
+	// fieldManager is the field manager of the objects applied with server-side apply
+	fieldManager = "hub-of-hubs-operator"
+	// ownerAnnotationKey records the namespace and name of the Config that owns a deployed object,
+	// owner references can't be used because the deployed objects are cluster scoped or in other namespaces
+	ownerAnnotationKey = "hubofhubs.open-cluster-management.io/owner"
+	// dataBearingAnnotationKey marks the objects in the manifests that hold data, they are kept on
+	// uninstallation unless the deletion policy of the Config is Delete
+	dataBearingAnnotationKey = "hubofhubs.open-cluster-management.io/data-bearing"
+)
+
+// ownedObject is a kind of object deployed by the operator that is watched to correct the drift
+type ownedObject struct {
+	object client.Object
+	// hasStatus is true for the kinds that update their status frequently, only the changes of
+	// the generation and metadata of those objects trigger a reconciliation
+	hasStatus bool
+}
+
+// ownedObjects returns the kinds of the objects deployed by the operator in the hub-of-hubs cluster
+func ownedObjects() []ownedObject {
+	return []ownedObject{
+		{object: &corev1.Namespace{}},
+		{object: &corev1.ServiceAccount{}},
+		{object: &corev1.ConfigMap{}},
+		{object: &corev1.Secret{}},
+		{object: &corev1.Service{}},
+		{object: &rbacv1.Role{}},
+		{object: &rbacv1.RoleBinding{}},
+		{object: &rbacv1.ClusterRole{}},
+		{object: &rbacv1.ClusterRoleBinding{}},
+		{object: &networkingv1.Ingress{}},
+		{object: &appsv1.Deployment{}, hasStatus: true},
+		{object: &batchv1.Job{}, hasStatus: true},
+		{object: &cdpov1beta1.PostgresCluster{}, hasStatus: true},
+		{object: newUnstructured(schema.GroupVersionKind{
+			Group: "kafka.strimzi.io", Version: "v1beta2", Kind: "Kafka"}), hasStatus: true},
+		{object: newUnstructured(schema.GroupVersionKind{
+			Group: "kafka.strimzi.io", Version: "v1beta2", Kind: "KafkaTopic"}), hasStatus: true},
+		{object: newUnstructured(schema.GroupVersionKind{
+			Group: "route.openshift.io", Version: "v1", Kind: "Route"})},
+	}
+}
+
+// ownedObjectPredicate filters the events of the objects deployed by the operator
+func ownedObjectPredicate(owned ownedObject) predicate.Predicate {
+	isManaged := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return obj.GetLabels()[managedByLabelKey] == managedByLabelValue
+	})
+	if !owned.hasStatus {
+		return isManaged
+	}
+
+	return predicate.And(isManaged, predicate.Or(
+		predicate.GenerationChangedPredicate{},
+		predicate.LabelChangedPredicate{},
+		predicate.AnnotationChangedPredicate{},
+	))
+}
+
+// setOwner labels the given object as deployed by the operator and records the owning Config
+func setOwner(obj runtime.Object, hohConfig *hubofhubsv1alpha1.Config) error {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return err
+	}
+
+	labels := accessor.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[managedByLabelKey] = managedByLabelValue
+	accessor.SetLabels(labels)
+
+	annotations := accessor.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[ownerAnnotationKey] = hohConfig.GetNamespace() + "/" + hohConfig.GetName()
+	accessor.SetAnnotations(annotations)
+
+	return nil
+}
+
+// enqueueOwner maps an event of a deployed object to the reconcile request of its owning Config
+func (r *ConfigReconciler) enqueueOwner(obj client.Object) []reconcile.Request {
+	owner := strings.SplitN(obj.GetAnnotations()[ownerAnnotationKey], "/", 2)
+	if len(owner) != 2 {
+		return r.enqueueConfigs(obj)
+	}
+
+	return []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Namespace: owner[0], Name: owner[1]}},
+	}
+}
+
+// objectKeysAndValues returns the kind, namespace and name of an object to log it, the objects themselves
+// aren't logged because the rendered secrets hold credentials
+func objectKeysAndValues(obj runtime.Object) []interface{} {
+	keysAndValues := []interface{}{"kind", obj.GetObjectKind().GroupVersionKind().Kind}
+	if accessor, err := meta.Accessor(obj); err == nil {
+		keysAndValues = append(keysAndValues, "namespace", accessor.GetNamespace(), "name", accessor.GetName())
+	}
+	return keysAndValues
+}
+
+func newUnstructured(gvk schema.GroupVersionKind) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(gvk)
+	return obj
+}
@@ -0,0 +1,171 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hubofhubs
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"sigs.k8s.io/yaml"
+
+	hubofhubsv1alpha1 "github.com/stolostron/hub-of-hubs-operator/apis/hubofhubs/v1alpha1"
+)
+
+// patchResults records the patches of the Config that were applied to the rendered objects and the ones
+// that failed, to report them in the Config status
+type patchResults struct {
+	matched []bool
+	errs    []string
+}
+
+func newPatchResults(hohConfig *hubofhubsv1alpha1.Config) *patchResults {
+	return &patchResults{matched: make([]bool, len(hohConfig.Spec.Patches))}
+}
+
+// patchObjects applies the patches of the Config in order to the rendered objects they target
+func (r *ConfigReconciler) patchObjects(hohConfig *hubofhubsv1alpha1.Config, objects []runtime.Object,
+	results *patchResults,
+) ([]runtime.Object, error) {
+	if len(hohConfig.Spec.Patches) == 0 {
+		return objects, nil
+	}
+
+	patchedObjects := make([]runtime.Object, 0, len(objects))
+	for _, obj := range objects {
+		for i, patch := range hohConfig.Spec.Patches {
+			if !patchTargets(patch.Target, obj) {
+				continue
+			}
+			results.matched[i] = true
+
+			patched, err := r.applyPatch(patch, obj)
+			if err != nil {
+				accessor, _ := meta.Accessor(obj)
+				err = fmt.Errorf("failed to apply the patch %d to %s %s: %w", i,
+					obj.GetObjectKind().GroupVersionKind().Kind, accessor.GetName(), err)
+				results.errs = append(results.errs, err.Error())
+				return nil, err
+			}
+			obj = patched
+		}
+		patchedObjects = append(patchedObjects, obj)
+	}
+
+	return patchedObjects, nil
+}
+
+// patchTargets returns true if the object is selected by the target of a patch
+func patchTargets(target hubofhubsv1alpha1.PatchTarget, obj runtime.Object) bool {
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return false
+	}
+	return gvk.Group == target.Group && gvk.Kind == target.Kind &&
+		(target.Name == "" || accessor.GetName() == target.Name) &&
+		(target.Namespace == "" || accessor.GetNamespace() == target.Namespace)
+}
+
+// applyPatch returns the object patched with the given patch, the patch can't change the identity of the object
+// because the deployed objects are tracked by it
+func (r *ConfigReconciler) applyPatch(patch hubofhubsv1alpha1.PatchConfig, obj runtime.Object,
+) (runtime.Object, error) {
+	original, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	patchJSON, err := yaml.YAMLToJSON([]byte(patch.Patch))
+	if err != nil {
+		return nil, err
+	}
+
+	var patchedJSON []byte
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	switch patch.Type {
+	case hubofhubsv1alpha1.JSON6902PatchType:
+		operations, err := jsonpatch.DecodePatch(patchJSON)
+		if err != nil {
+			return nil, err
+		}
+		patchedJSON, err = operations.Apply(original)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		// the kinds without a Go type, such as the Kafka custom resources, have no strategic merge metadata
+		if typed, err := r.Scheme.New(gvk); err == nil {
+			patchedJSON, err = strategicpatch.StrategicMergePatch(original, patchJSON, typed)
+			if err != nil {
+				return nil, err
+			}
+		} else if runtime.IsNotRegisteredError(err) {
+			patchedJSON, err = jsonpatch.MergePatch(original, patchJSON)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			return nil, err
+		}
+	}
+
+	patched := &unstructured.Unstructured{}
+	if err := patched.UnmarshalJSON(patchedJSON); err != nil {
+		return nil, err
+	}
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return nil, err
+	}
+	if patched.GroupVersionKind() != gvk || patched.GetName() != accessor.GetName() ||
+		patched.GetNamespace() != accessor.GetNamespace() {
+		return nil, fmt.Errorf("the patch must not change the apiVersion, kind, name or namespace")
+	}
+
+	return patched, nil
+}
+
+// setPatchesCondition reports the patches that failed or didn't match any rendered object,
+// the condition is removed when the Config has no patches
+func setPatchesCondition(hohConfig *hubofhubsv1alpha1.Config, results *patchResults) {
+	if len(hohConfig.Spec.Patches) == 0 {
+		meta.RemoveStatusCondition(&hohConfig.Status.Conditions, hubofhubsv1alpha1.ConditionTypePatchesApplied)
+		return
+	}
+
+	messages := results.errs
+	for i, matched := range results.matched {
+		if !matched {
+			target := hohConfig.Spec.Patches[i].Target
+			messages = append(messages, fmt.Sprintf("the patch %d matches no object of kind %s", i, target.Kind))
+		}
+	}
+	if len(messages) > 0 {
+		setCondition(hohConfig, hubofhubsv1alpha1.ConditionTypePatchesApplied, metav1.ConditionFalse,
+			reasonPatchFailed, strings.Join(messages, "; "))
+		return
+	}
+
+	setCondition(hohConfig, hubofhubsv1alpha1.ConditionTypePatchesApplied, metav1.ConditionTrue,
+		reasonPatched, "all the patches are applied")
+}
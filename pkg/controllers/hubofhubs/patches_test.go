@@ -0,0 +1,247 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hubofhubs
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	hubofhubsv1alpha1 "github.com/stolostron/hub-of-hubs-operator/apis/hubofhubs/v1alpha1"
+)
+
+// testDeployment returns a Deployment with the given namespace and name
+func testDeployment(namespace, name string) runtime.Object {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("apps/v1")
+	obj.SetKind("Deployment")
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	return obj
+}
+
+// labeledObjects returns the names of the objects that have the label patched
+func labeledObjects(t *testing.T, objects []runtime.Object) []string {
+	t.Helper()
+	names := []string{}
+	for _, obj := range objects {
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if accessor.GetLabels()["patched"] == "true" {
+			names = append(names, accessor.GetNamespace()+"/"+accessor.GetName())
+		}
+	}
+	return names
+}
+
+func TestPatchTargets(t *testing.T) {
+	objects := []runtime.Object{
+		testDeployment("hoh", "manager"),
+		testDeployment("hoh", "rbac"),
+		testDeployment("hoh-system", "manager"),
+		testObject("manager", false),
+	}
+	labelPatch := "metadata:\n  labels:\n    patched: \"true\"\n"
+
+	tests := []struct {
+		name        string
+		target      hubofhubsv1alpha1.PatchTarget
+		wantPatched []string
+		wantMatched bool
+	}{
+		{
+			name:        "kind",
+			target:      hubofhubsv1alpha1.PatchTarget{Group: "apps", Kind: "Deployment"},
+			wantPatched: []string{"hoh/manager", "hoh/rbac", "hoh-system/manager"},
+			wantMatched: true,
+		},
+		{
+			name:        "kind and name",
+			target:      hubofhubsv1alpha1.PatchTarget{Group: "apps", Kind: "Deployment", Name: "manager"},
+			wantPatched: []string{"hoh/manager", "hoh-system/manager"},
+			wantMatched: true,
+		},
+		{
+			name: "kind, name and namespace",
+			target: hubofhubsv1alpha1.PatchTarget{Group: "apps", Kind: "Deployment", Name: "manager",
+				Namespace: "hoh-system"},
+			wantPatched: []string{"hoh-system/manager"},
+			wantMatched: true,
+		},
+		{
+			name:        "core group",
+			target:      hubofhubsv1alpha1.PatchTarget{Kind: "ConfigMap", Name: "manager"},
+			wantPatched: []string{"hoh/manager"},
+			wantMatched: true,
+		},
+		{
+			name:        "kind of another group",
+			target:      hubofhubsv1alpha1.PatchTarget{Kind: "Deployment"},
+			wantPatched: []string{},
+		},
+		{
+			name:        "no object with the name",
+			target:      hubofhubsv1alpha1.PatchTarget{Group: "apps", Kind: "Deployment", Name: "missing"},
+			wantPatched: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hohConfig := newTestConfig()
+			hohConfig.Spec.Patches = []hubofhubsv1alpha1.PatchConfig{{Target: tt.target, Patch: labelPatch}}
+			r := newTestReconciler(t)
+
+			results := newPatchResults(hohConfig)
+			patched, err := r.patchObjects(hohConfig, objects, results)
+			if err != nil {
+				t.Fatalf("patchObjects() failed: %v", err)
+			}
+			if got := labeledObjects(t, patched); !reflect.DeepEqual(got, tt.wantPatched) {
+				t.Errorf("patched %v, want %v", got, tt.wantPatched)
+			}
+			if len(patched) != len(objects) {
+				t.Errorf("patchObjects() returned %d objects, want %d", len(patched), len(objects))
+			}
+
+			setPatchesCondition(hohConfig, results)
+			condition := meta.FindStatusCondition(hohConfig.Status.Conditions,
+				hubofhubsv1alpha1.ConditionTypePatchesApplied)
+			if condition == nil {
+				t.Fatal("the PatchesApplied condition is not set")
+			}
+			wantReason := reasonPatchFailed
+			if tt.wantMatched {
+				wantReason = reasonPatched
+			}
+			if condition.Reason != wantReason {
+				t.Errorf("the condition reason is %s, want %s", condition.Reason, wantReason)
+			}
+		})
+	}
+}
+
+func TestPatchFailed(t *testing.T) {
+	target := hubofhubsv1alpha1.PatchTarget{Group: "apps", Kind: "Deployment", Name: "manager"}
+
+	tests := []struct {
+		name    string
+		patch   hubofhubsv1alpha1.PatchConfig
+		wantErr string
+	}{
+		{
+			name:    "patch that isn't YAML",
+			patch:   hubofhubsv1alpha1.PatchConfig{Target: target, Patch: "metadata: ["},
+			wantErr: "failed to apply the patch 0 to Deployment manager",
+		},
+		{
+			name: "JSON6902 operation on a missing path",
+			patch: hubofhubsv1alpha1.PatchConfig{Target: target, Type: hubofhubsv1alpha1.JSON6902PatchType,
+				Patch: "- op: replace\n  path: /spec/missing/field\n  value: 1\n"},
+			wantErr: "failed to apply the patch 0 to Deployment manager",
+		},
+		{
+			name:    "patch that renames the object",
+			patch:   hubofhubsv1alpha1.PatchConfig{Target: target, Patch: "metadata:\n  name: renamed\n"},
+			wantErr: "the patch must not change the apiVersion, kind, name or namespace",
+		},
+		{
+			name: "patch that moves the object",
+			patch: hubofhubsv1alpha1.PatchConfig{Target: target, Type: hubofhubsv1alpha1.JSON6902PatchType,
+				Patch: "- op: replace\n  path: /metadata/namespace\n  value: other\n"},
+			wantErr: "the patch must not change the apiVersion, kind, name or namespace",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hohConfig := newTestConfig()
+			hohConfig.Spec.Patches = []hubofhubsv1alpha1.PatchConfig{tt.patch}
+			r := newTestReconciler(t)
+
+			results := newPatchResults(hohConfig)
+			_, err := r.patchObjects(hohConfig, []runtime.Object{testDeployment("hoh", "manager")}, results)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("patchObjects() returned %v, want an error containing %q", err, tt.wantErr)
+			}
+
+			setPatchesCondition(hohConfig, results)
+			condition := meta.FindStatusCondition(hohConfig.Status.Conditions,
+				hubofhubsv1alpha1.ConditionTypePatchesApplied)
+			if condition == nil {
+				t.Fatal("the PatchesApplied condition is not set")
+			}
+			if condition.Status != metav1.ConditionFalse || condition.Reason != reasonPatchFailed {
+				t.Errorf("the condition is %s/%s, want %s/%s", condition.Status, condition.Reason,
+					metav1.ConditionFalse, reasonPatchFailed)
+			}
+			if !strings.Contains(condition.Message, tt.wantErr) {
+				t.Errorf("the condition message %q doesn't contain %q", condition.Message, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPatchBeforeInventory(t *testing.T) {
+	ctx := context.TODO()
+	hohConfig := newTestConfig()
+	hohConfig.Spec.DeletionPolicy = hubofhubsv1alpha1.RetainDeletionPolicy
+	// the patch marks a rendered object as data-bearing, so it must be retained once it's no longer rendered
+	hohConfig.Spec.Patches = []hubofhubsv1alpha1.PatchConfig{{
+		Target: hubofhubsv1alpha1.PatchTarget{Kind: "ConfigMap", Name: "data"},
+		Patch:  "metadata:\n  annotations:\n    " + dataBearingAnnotationKey + ": \"true\"\n",
+	}}
+	r := newTestReconciler(t, hohConfig)
+	hohDeployer := &fakeDeployer{undeployed: []string{}}
+
+	objects, err := r.patchObjects(hohConfig, []runtime.Object{testObject("a", false), testObject("data", false)},
+		newPatchResults(hohConfig))
+	if err != nil {
+		t.Fatalf("patchObjects() failed: %v", err)
+	}
+	inv := inventory{}
+	if err := r.recordComponent(ctx, hohConfig, hohDeployer, inv, "component", objects,
+		testRefs(t, objects...)); err != nil {
+		t.Fatalf("recordComponent() failed: %v", err)
+	}
+
+	saved, err := r.getInventory(ctx, hohConfig)
+	if err != nil {
+		t.Fatalf("getInventory() failed: %v", err)
+	}
+	for _, ref := range saved["component"] {
+		if ref.DataBearing != (ref.Name == "data") {
+			t.Errorf("the inventory records %s as data-bearing: %t", ref.Name, ref.DataBearing)
+		}
+	}
+
+	// the object that isn't rendered anymore is retained according to the patched annotation
+	objects = []runtime.Object{testObject("a", false)}
+	if err := r.recordComponent(ctx, hohConfig, hohDeployer, saved, "component", objects,
+		testRefs(t, objects...)); err != nil {
+		t.Fatalf("recordComponent() failed: %v", err)
+	}
+	if len(hohDeployer.undeployed) != 0 {
+		t.Errorf("undeployed %v, want none", hohDeployer.undeployed)
+	}
+}
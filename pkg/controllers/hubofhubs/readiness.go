@@ -0,0 +1,222 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hubofhubs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cdpov1beta1 "github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+
+	hubofhubsv1alpha1 "github.com/stolostron/hub-of-hubs-operator/apis/hubofhubs/v1alpha1"
+)
+
+const (
+	postgresNamespace      = "hoh-postgres"
+	postgresClusterName    = "hoh"
+	postgresInitJobName    = "postgres-init"
+	managerNamespace       = "open-cluster-management"
+	managerDeploymentName  = "hub-of-hubs-manager"
+	cssDeploymentName      = "sync-service-css"
+	postgresUserSecretBase = postgresClusterName + "-pguser-"
+	postgresClusterLabel   = "postgres-operator.crunchydata.com/cluster"
+	hubConfigNamespace     = "hoh-system"
+	hubConfigName          = "hub-of-hubs-config"
+	// readinessRequeuePeriod is the period to check again the components that are not ready
+	readinessRequeuePeriod = 10 * time.Second
+)
+
+const (
+	postgresProcessUser         = "hoh-process-user"
+	postgresTransportBridgeUser = "transport-bridge-user"
+)
+
+// postgresUsers are the users of the PostgresCluster, PGO creates a secret for each of them
+var postgresUsers = []string{"postgres", postgresProcessUser, postgresTransportBridgeUser}
+
+// readinessCheck returns true if a component is ready, otherwise it returns a message explaining what is missing
+type readinessCheck func(context.Context, *hubofhubsv1alpha1.Config) (bool, string, error)
+
+// databaseReady checks that all the instances of the PostgresCluster are ready and that PGO created
+// the secrets of the users, or that the secrets of an external database are complete
+func (r *ConfigReconciler) databaseReady(ctx context.Context, hohConfig *hubofhubsv1alpha1.Config,
+) (bool, string, error) {
+	if external := getExternalDatabase(hohConfig); external != nil {
+		return r.externalDatabaseReady(ctx, hohConfig, external)
+	}
+
+	postgresCluster := &cdpov1beta1.PostgresCluster{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: postgresNamespace, Name: postgresClusterName},
+		postgresCluster); err != nil {
+		return false, "", err
+	}
+
+	if len(postgresCluster.Status.InstanceSets) == 0 {
+		return false, "waiting for the instances of the PostgresCluster to be created", nil
+	}
+	for _, instanceSet := range postgresCluster.Status.InstanceSets {
+		if instanceSet.ReadyReplicas < instanceSet.Replicas || instanceSet.Replicas == 0 {
+			return false, fmt.Sprintf("waiting for the instances of the PostgresCluster to be ready: %d/%d in %s",
+				instanceSet.ReadyReplicas, instanceSet.Replicas, instanceSet.Name), nil
+		}
+	}
+
+	// the volumes are expanded after their requested size is increased
+	volumes := &corev1.PersistentVolumeClaimList{}
+	if err := r.List(ctx, volumes, client.InNamespace(postgresNamespace),
+		client.MatchingLabels{postgresClusterLabel: postgresClusterName}); err != nil {
+		return false, "", err
+	}
+	for _, volume := range volumes.Items {
+		requested := volume.Spec.Resources.Requests[corev1.ResourceStorage]
+		capacity := volume.Status.Capacity[corev1.ResourceStorage]
+		if capacity.Cmp(requested) < 0 {
+			return false, fmt.Sprintf("waiting for the volume %s to be expanded from %s to %s",
+				volume.Name, capacity.String(), requested.String()), nil
+		}
+	}
+
+	for _, user := range postgresUsers {
+		secretName := postgresUserSecretBase + user
+		if err := r.Get(ctx, types.NamespacedName{Namespace: postgresNamespace, Name: secretName},
+			&corev1.Secret{}); err != nil {
+			if errors.IsNotFound(err) {
+				return false, fmt.Sprintf("waiting for the secret %s to be created", secretName), nil
+			}
+			return false, "", err
+		}
+	}
+
+	return true, "", nil
+}
+
+// databaseInitialized checks that the database initialization job succeeded
+func (r *ConfigReconciler) databaseInitialized(ctx context.Context, hohConfig *hubofhubsv1alpha1.Config,
+) (bool, string, error) {
+	jobNamespace := postgresNamespace
+	if getExternalDatabase(hohConfig) != nil {
+		jobNamespace = hohConfig.GetNamespace()
+	}
+
+	job := &batchv1.Job{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: jobNamespace, Name: postgresInitJobName},
+		job); err != nil {
+		return false, "", err
+	}
+
+	if job.Status.Succeeded > 0 {
+		return true, "", nil
+	}
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == batchv1.JobFailed && condition.Status == corev1.ConditionTrue {
+			return false, "", fmt.Errorf("the job %s failed: %s", postgresInitJobName, condition.Message)
+		}
+	}
+
+	return false, fmt.Sprintf("waiting for the job %s to succeed", postgresInitJobName), nil
+}
+
+// transportReady checks that the Kafka cluster and its topics are ready or that the sync-service CSS
+// is available with a serving certificate for its route, the topics of an external Kafka cluster must exist
+func (r *ConfigReconciler) transportReady(ctx context.Context, hohConfig *hubofhubsv1alpha1.Config,
+) (bool, string, error) {
+	if getTransportType(hohConfig) == string(hubofhubsv1alpha1.SyncServiceTransportProvider) {
+		hohConfig.Status.KafkaTopics = nil
+		if available, message, err := r.deploymentAvailable(ctx, syncServiceNamespace,
+			cssDeploymentName); !available || err != nil {
+			return available, message, err
+		}
+		return r.cssServingCertReady(ctx)
+	}
+	if getExternalKafka(hohConfig) != nil {
+		hohConfig.Status.KafkaTopics = nil
+		return r.externalKafkaReady(ctx, hohConfig)
+	}
+
+	// the status of the topics is reported even when the kafka cluster is not ready
+	topicsReady, topicsMessage, err := r.kafkaTopicsReady(ctx, hohConfig)
+	if err != nil {
+		return false, "", err
+	}
+
+	kafkaCluster := &unstructured.Unstructured{}
+	kafkaCluster.SetAPIVersion("kafka.strimzi.io/v1beta2")
+	kafkaCluster.SetKind("Kafka")
+	if err := r.Get(ctx, types.NamespacedName{Namespace: kafkaNamespace, Name: kafkaClusterName},
+		kafkaCluster); err != nil {
+		return false, "", err
+	}
+
+	conditions, _, err := unstructured.NestedSlice(kafkaCluster.Object, "status", "conditions")
+	if err != nil {
+		return false, "", err
+	}
+	for _, condition := range conditions {
+		conditionMap, ok := condition.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if conditionMap["type"] == "Ready" && conditionMap["status"] == "True" {
+			return topicsReady, topicsMessage, nil
+		}
+	}
+
+	return false, fmt.Sprintf("waiting for the kafka cluster %s to be ready", kafkaClusterName), nil
+}
+
+// hubConfigReady checks that the hub-of-hubs Config read by the manager exists
+func (r *ConfigReconciler) hubConfigReady(ctx context.Context, _ *hubofhubsv1alpha1.Config) (bool, string, error) {
+	hubConfig := &unstructured.Unstructured{}
+	hubConfig.SetAPIVersion("hub-of-hubs.open-cluster-management.io/v1")
+	hubConfig.SetKind("Config")
+	if err := r.Get(ctx, types.NamespacedName{Namespace: hubConfigNamespace, Name: hubConfigName},
+		hubConfig); err != nil {
+		return false, "", err
+	}
+
+	return true, "", nil
+}
+
+// managerReady checks that the hub-of-hubs manager deployment is available
+func (r *ConfigReconciler) managerReady(ctx context.Context, _ *hubofhubsv1alpha1.Config) (bool, string, error) {
+	return r.deploymentAvailable(ctx, managerNamespace, managerDeploymentName)
+}
+
+// deploymentAvailable checks that the given deployment has the Available condition
+func (r *ConfigReconciler) deploymentAvailable(ctx context.Context, namespace, name string) (bool, string, error) {
+	deployment := &appsv1.Deployment{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, deployment); err != nil {
+		return false, "", err
+	}
+
+	for _, condition := range deployment.Status.Conditions {
+		if condition.Type == appsv1.DeploymentAvailable && condition.Status == corev1.ConditionTrue {
+			return true, "", nil
+		}
+	}
+
+	return false, fmt.Sprintf("waiting for the deployment %s/%s to be available", namespace, name), nil
+}
@@ -0,0 +1,212 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hubofhubs
+
+import (
+	"time"
+
+	hubofhubsv1alpha1 "github.com/stolostron/hub-of-hubs-operator/apis/hubofhubs/v1alpha1"
+)
+
+// the defaults of the settings of the core components, they match the defaults of the Config CRD
+const (
+	defaultSyncInterval          = 5 * time.Second
+	defaultMsgCompressType       = hubofhubsv1alpha1.GzipMsgCompressType
+	defaultMsgSizeLimit          = 940
+	defaultCommitterInterval     = 5 * time.Second
+	defaultStatisticsLogInterval = 5 * time.Second
+	defaultKubeClientPoolSize    = 10
+	defaultControlInfoInterval   = time.Hour
+	defaultDeltaCountSwitch      = 100
+	defaultHeartbeatInterval     = 60 * time.Second
+	defaultNonk8sAPIBasePath     = "/multicloud/hub-of-hubs-nonk8s-api"
+	defaultIngressClassName      = "ingress-open-cluster-management"
+)
+
+// defaultNonk8sAPIAnnotations make the management ingress check the access token of the requests
+// and connect to the manager with TLS
+var defaultNonk8sAPIAnnotations = map[string]string{
+	"ingress.open-cluster-management.io/auth-type":       "access-token",
+	"ingress.open-cluster-management.io/secure-backends": "true",
+}
+
+// managerSettings contains the settings of the hub-of-hubs manager, rendered into its flags
+type managerSettings struct {
+	StatusSyncInterval                   time.Duration
+	SpecTransportSyncInterval            time.Duration
+	SpecTransportMsgCompressType         string
+	SpecTransportMsgSizeLimit            uint64
+	StatusTransportCommitterInterval     time.Duration
+	StatusTransportStatisticsLogInterval time.Duration
+}
+
+// getManagerSettings returns the settings of the manager from the hoh core config, the unset settings
+// have their default values
+func getManagerSettings(hohConfig *hubofhubsv1alpha1.Config) managerSettings {
+	settings := managerSettings{
+		StatusSyncInterval:                   defaultSyncInterval,
+		SpecTransportSyncInterval:            defaultSyncInterval,
+		SpecTransportMsgCompressType:         string(defaultMsgCompressType),
+		SpecTransportMsgSizeLimit:            defaultMsgSizeLimit,
+		StatusTransportCommitterInterval:     defaultCommitterInterval,
+		StatusTransportStatisticsLogInterval: defaultStatisticsLogInterval,
+	}
+
+	components := hohConfig.Spec.Components
+	if components == nil || components.Core == nil || components.Core.Hoh == nil {
+		return settings
+	}
+	hoh := components.Core.Hoh
+
+	if statusSync := hoh.StatusSync; statusSync != nil {
+		overrideInterval(&settings.StatusSyncInterval, statusSync.SyncInterval)
+	}
+	if specTransportBridge := hoh.SpecTransportBridge; specTransportBridge != nil {
+		overrideInterval(&settings.SpecTransportSyncInterval, specTransportBridge.SyncInterval)
+		if specTransportBridge.MsgCompressType != "" {
+			settings.SpecTransportMsgCompressType = string(specTransportBridge.MsgCompressType)
+		}
+		if specTransportBridge.MsgSizeLimit > 0 {
+			settings.SpecTransportMsgSizeLimit = specTransportBridge.MsgSizeLimit
+		}
+	}
+	if statusTransportBridge := hoh.StatusTransportBridge; statusTransportBridge != nil {
+		overrideInterval(&settings.StatusTransportCommitterInterval, statusTransportBridge.CommitterInterval)
+		overrideInterval(&settings.StatusTransportStatisticsLogInterval, statusTransportBridge.StatisticsLogInterval)
+	}
+
+	return settings
+}
+
+// nonk8sAPISettings contains the settings of the nonk8s-API of the manager and of its exposure
+type nonk8sAPISettings struct {
+	BasePath         string
+	Exposure         string
+	Host             string
+	IngressClassName string
+	TLSSecretName    string
+	Annotations      map[string]string
+}
+
+// getNonk8sAPISettings returns the settings of the nonk8s-API from the hoh core config, the API is exposed
+// with the management ingress by default
+func getNonk8sAPISettings(hohConfig *hubofhubsv1alpha1.Config) nonk8sAPISettings {
+	settings := nonk8sAPISettings{
+		BasePath:         defaultNonk8sAPIBasePath,
+		Exposure:         string(hubofhubsv1alpha1.IngressNonk8sAPIExposure),
+		IngressClassName: defaultIngressClassName,
+	}
+
+	if components := hohConfig.Spec.Components; components != nil && components.Core != nil &&
+		components.Core.Hoh != nil && components.Core.Hoh.Nonk8sAPI != nil {
+		nonk8sAPI := components.Core.Hoh.Nonk8sAPI
+		if nonk8sAPI.BasePath != "" {
+			settings.BasePath = nonk8sAPI.BasePath
+		}
+		if nonk8sAPI.Exposure != "" {
+			settings.Exposure = string(nonk8sAPI.Exposure)
+		}
+		settings.Host = nonk8sAPI.Host
+		if nonk8sAPI.IngressClassName != "" {
+			settings.IngressClassName = nonk8sAPI.IngressClassName
+		}
+		if nonk8sAPI.TLSSecret != nil {
+			settings.TLSSecretName = nonk8sAPI.TLSSecret.Name
+		}
+		if len(nonk8sAPI.Annotations) > 0 {
+			settings.Annotations = nonk8sAPI.Annotations
+		}
+	}
+
+	// the default annotations are only understood by the management ingress
+	if settings.Annotations == nil && settings.Exposure == string(hubofhubsv1alpha1.IngressNonk8sAPIExposure) {
+		settings.Annotations = defaultNonk8sAPIAnnotations
+	}
+
+	return settings
+}
+
+// overrideInterval sets the interval to the given number of seconds if it is set
+func overrideInterval(interval *time.Duration, seconds uint64) {
+	if seconds > 0 {
+		*interval = time.Duration(seconds) * time.Second
+	}
+}
+
+// agentSettings contains the settings of the hub-of-hubs agent of the leaf hubs, rendered into its flags
+// and into the sync-intervals ConfigMap
+type agentSettings struct {
+	KubeClientPoolSize         uint64
+	EnforceHoHRbac             bool
+	ManagedClusterSyncInterval time.Duration
+	PolicySyncInterval         time.Duration
+	ControlInfoSyncInterval    time.Duration
+	DeltaSentCountSwitchFactor uint64
+	MsgCompressType            string
+	MsgSizeLimit               uint64
+	HeartbeatInterval          time.Duration
+}
+
+// getAgentSettings returns the settings of the agents from the leaf hub core config and the heartbeat
+// interval of the global config, the unset settings have their default values
+func getAgentSettings(hohConfig *hubofhubsv1alpha1.Config) agentSettings {
+	settings := agentSettings{
+		KubeClientPoolSize:         defaultKubeClientPoolSize,
+		ManagedClusterSyncInterval: defaultSyncInterval,
+		PolicySyncInterval:         defaultSyncInterval,
+		ControlInfoSyncInterval:    defaultControlInfoInterval,
+		DeltaSentCountSwitchFactor: defaultDeltaCountSwitch,
+		MsgCompressType:            string(defaultMsgCompressType),
+		MsgSizeLimit:               defaultMsgSizeLimit,
+		HeartbeatInterval:          defaultHeartbeatInterval,
+	}
+
+	if global := hohConfig.Spec.Global; global != nil && global.HeartbeatInterval != nil {
+		overrideInterval(&settings.HeartbeatInterval, global.HeartbeatInterval.LeafHub)
+	}
+
+	components := hohConfig.Spec.Components
+	if components == nil || components.Core == nil || components.Core.LeafHub == nil {
+		return settings
+	}
+	leafHub := components.Core.LeafHub
+
+	if specSync := leafHub.SpecSync; specSync != nil {
+		if specSync.KubeClientPoolSIze > 0 {
+			settings.KubeClientPoolSize = specSync.KubeClientPoolSIze
+		}
+		settings.EnforceHoHRbac = specSync.EnforceHoHRbac
+	}
+	if statusSync := leafHub.StatusSync; statusSync != nil {
+		if syncInterval := statusSync.SyncInterval; syncInterval != nil {
+			overrideInterval(&settings.ManagedClusterSyncInterval, syncInterval.ManagedClusterSyncInterval)
+			overrideInterval(&settings.PolicySyncInterval, syncInterval.PolicySyncInterval)
+			overrideInterval(&settings.ControlInfoSyncInterval, syncInterval.ControlInfoSyncInterval)
+		}
+		if statusSync.DeltaSentCountSwitchFactor > 0 {
+			settings.DeltaSentCountSwitchFactor = statusSync.DeltaSentCountSwitchFactor
+		}
+		if statusSync.MsgCompressType != "" {
+			settings.MsgCompressType = string(statusSync.MsgCompressType)
+		}
+		if statusSync.MsgSizeLimit > 0 {
+			settings.MsgSizeLimit = statusSync.MsgSizeLimit
+		}
+	}
+
+	return settings
+}
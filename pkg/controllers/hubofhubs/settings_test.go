@@ -0,0 +1,174 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hubofhubs
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	hubofhubsv1alpha1 "github.com/stolostron/hub-of-hubs-operator/apis/hubofhubs/v1alpha1"
+)
+
+func TestGetManagerSettings(t *testing.T) {
+	defaults := managerSettings{
+		StatusSyncInterval:                   5 * time.Second,
+		SpecTransportSyncInterval:            5 * time.Second,
+		SpecTransportMsgCompressType:         "gzip",
+		SpecTransportMsgSizeLimit:            940,
+		StatusTransportCommitterInterval:     5 * time.Second,
+		StatusTransportStatisticsLogInterval: 5 * time.Second,
+	}
+
+	tests := []struct {
+		name string
+		hoh  *hubofhubsv1alpha1.HohConfig
+		want managerSettings
+	}{
+		{
+			name: "defaults",
+			want: defaults,
+		},
+		{
+			name: "empty settings",
+			hoh: &hubofhubsv1alpha1.HohConfig{
+				StatusSync:            &hubofhubsv1alpha1.StatusSyncConfig{},
+				SpecTransportBridge:   &hubofhubsv1alpha1.SpecTransportBridgeConfig{},
+				StatusTransportBridge: &hubofhubsv1alpha1.StatusTransportBridgeConfig{},
+			},
+			want: defaults,
+		},
+		{
+			name: "overrides",
+			hoh: &hubofhubsv1alpha1.HohConfig{
+				StatusSync: &hubofhubsv1alpha1.StatusSyncConfig{SyncInterval: 10},
+				SpecTransportBridge: &hubofhubsv1alpha1.SpecTransportBridgeConfig{
+					SyncInterval:    90,
+					MsgCompressType: hubofhubsv1alpha1.NoopMsgCompressType,
+					MsgSizeLimit:    500,
+				},
+				StatusTransportBridge: &hubofhubsv1alpha1.StatusTransportBridgeConfig{
+					CommitterInterval:     20,
+					StatisticsLogInterval: 3600,
+				},
+			},
+			want: managerSettings{
+				StatusSyncInterval:                   10 * time.Second,
+				SpecTransportSyncInterval:            90 * time.Second,
+				SpecTransportMsgCompressType:         "no-op",
+				SpecTransportMsgSizeLimit:            500,
+				StatusTransportCommitterInterval:     20 * time.Second,
+				StatusTransportStatisticsLogInterval: time.Hour,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hohConfig := newTestConfig()
+			if tt.hoh != nil {
+				hohConfig.Spec.Components = &hubofhubsv1alpha1.ComponentsConfig{
+					Core: &hubofhubsv1alpha1.CoreConfig{Hoh: tt.hoh},
+				}
+			}
+			if got := getManagerSettings(hohConfig); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getManagerSettings() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetAgentSettings(t *testing.T) {
+	defaults := agentSettings{
+		KubeClientPoolSize:         10,
+		ManagedClusterSyncInterval: 5 * time.Second,
+		PolicySyncInterval:         5 * time.Second,
+		ControlInfoSyncInterval:    time.Hour,
+		DeltaSentCountSwitchFactor: 100,
+		MsgCompressType:            "gzip",
+		MsgSizeLimit:               940,
+		HeartbeatInterval:          time.Minute,
+	}
+
+	tests := []struct {
+		name    string
+		global  *hubofhubsv1alpha1.GlobalConfig
+		leafHub *hubofhubsv1alpha1.LeafHubConfig
+		want    agentSettings
+	}{
+		{
+			name: "defaults",
+			want: defaults,
+		},
+		{
+			name: "empty settings",
+			global: &hubofhubsv1alpha1.GlobalConfig{
+				HeartbeatInterval: &hubofhubsv1alpha1.HeartbeatIntervalConfig{},
+			},
+			leafHub: &hubofhubsv1alpha1.LeafHubConfig{
+				SpecSync: &hubofhubsv1alpha1.LeafHubSpecSyncConfig{},
+				StatusSync: &hubofhubsv1alpha1.LeafHubStatusSyncConfig{
+					SyncInterval: &hubofhubsv1alpha1.LeafHubStatusSyncIntervalSettings{},
+				},
+			},
+			want: defaults,
+		},
+		{
+			name: "overrides",
+			global: &hubofhubsv1alpha1.GlobalConfig{
+				HeartbeatInterval: &hubofhubsv1alpha1.HeartbeatIntervalConfig{HoH: 10, LeafHub: 30},
+			},
+			leafHub: &hubofhubsv1alpha1.LeafHubConfig{
+				SpecSync: &hubofhubsv1alpha1.LeafHubSpecSyncConfig{KubeClientPoolSIze: 20, EnforceHoHRbac: true},
+				StatusSync: &hubofhubsv1alpha1.LeafHubStatusSyncConfig{
+					SyncInterval: &hubofhubsv1alpha1.LeafHubStatusSyncIntervalSettings{
+						ManagedClusterSyncInterval: 10,
+						PolicySyncInterval:         15,
+						ControlInfoSyncInterval:    1800,
+					},
+					DeltaSentCountSwitchFactor: 50,
+					MsgCompressType:            hubofhubsv1alpha1.NoopMsgCompressType,
+					MsgSizeLimit:               500,
+				},
+			},
+			want: agentSettings{
+				KubeClientPoolSize:         20,
+				EnforceHoHRbac:             true,
+				ManagedClusterSyncInterval: 10 * time.Second,
+				PolicySyncInterval:         15 * time.Second,
+				ControlInfoSyncInterval:    30 * time.Minute,
+				DeltaSentCountSwitchFactor: 50,
+				MsgCompressType:            "no-op",
+				MsgSizeLimit:               500,
+				HeartbeatInterval:          30 * time.Second,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hohConfig := newTestConfig()
+			hohConfig.Spec.Global = tt.global
+			if tt.leafHub != nil {
+				hohConfig.Spec.Components = &hubofhubsv1alpha1.ComponentsConfig{
+					Core: &hubofhubsv1alpha1.CoreConfig{LeafHub: tt.leafHub},
+				}
+			}
+			if got := getAgentSettings(hohConfig); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getAgentSettings() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
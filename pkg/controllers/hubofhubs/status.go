@@ -0,0 +1,112 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hubofhubs
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	hubofhubsv1alpha1 "github.com/stolostron/hub-of-hubs-operator/apis/hubofhubs/v1alpha1"
+)
+
+// condition reasons reported in the Config status
+const (
+	reasonDeployed          = "Deployed"
+	reasonDeployFailed      = "DeployFailed"
+	reasonRenderFailed      = "RenderFailed"
+	reasonPatched           = "Patched"
+	reasonPatchFailed       = "PatchFailed"
+	reasonReady             = "Ready"
+	reasonNotReady          = "NotReady"
+	reasonWaitingForDeps    = "WaitingForDependencies"
+	reasonComponentsReady   = "ComponentsReady"
+	reasonComponentNotReady = "ComponentNotReady"
+	reasonUninstalling      = "Uninstalling"
+	reasonUninstalled       = "Uninstalled"
+	reasonUninstallFailed   = "UninstallFailed"
+)
+
+// hubComponentConditionTypes are the condition types of the components deployed in the hub-of-hubs cluster,
+// all of them must be true for the Config to be ready
+var hubComponentConditionTypes = []string{
+	hubofhubsv1alpha1.ConditionTypeDatabase,
+	hubofhubsv1alpha1.ConditionTypeDatabaseInitialized,
+	hubofhubsv1alpha1.ConditionTypeTransport,
+	hubofhubsv1alpha1.ConditionTypeHubConfig,
+	hubofhubsv1alpha1.ConditionTypeManager,
+}
+
+// setCondition sets the given condition on the Config status, the last transition time
+// is only updated when the status of the condition changes
+func setCondition(hohConfig *hubofhubsv1alpha1.Config, conditionType string, status metav1.ConditionStatus,
+	reason, message string,
+) {
+	meta.SetStatusCondition(&hohConfig.Status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: hohConfig.GetGeneration(),
+	})
+}
+
+// setReadyConditionAndPhase computes the Ready condition and the overall phase from the component conditions
+func setReadyConditionAndPhase(hohConfig *hubofhubsv1alpha1.Config) {
+	if hohConfig.GetDeletionTimestamp() != nil {
+		setCondition(hohConfig, hubofhubsv1alpha1.ConditionTypeReady, metav1.ConditionFalse,
+			reasonUninstalling, "the Config is deleted, the hub-of-hubs components are being uninstalled")
+		hohConfig.Status.Phase = hubofhubsv1alpha1.ConfigPhaseUninstalling
+		return
+	}
+
+	for _, conditionType := range hubComponentConditionTypes {
+		condition := meta.FindStatusCondition(hohConfig.Status.Conditions, conditionType)
+		if condition == nil || condition.Status != metav1.ConditionTrue {
+			message := "component " + conditionType + " is not ready"
+			phase := hubofhubsv1alpha1.ConfigPhaseProgressing
+			if condition != nil && condition.Status == metav1.ConditionFalse {
+				message = "component " + conditionType + " is not ready: " + condition.Message
+				phase = hubofhubsv1alpha1.ConfigPhaseFailed
+			}
+			setCondition(hohConfig, hubofhubsv1alpha1.ConditionTypeReady, metav1.ConditionFalse,
+				reasonComponentNotReady, message)
+			hohConfig.Status.Phase = phase
+			return
+		}
+	}
+
+	setCondition(hohConfig, hubofhubsv1alpha1.ConditionTypeReady, metav1.ConditionTrue,
+		reasonComponentsReady, "all the hub-of-hubs components are ready")
+	hohConfig.Status.Phase = hubofhubsv1alpha1.ConfigPhaseRunning
+}
+
+// updateStatus writes the status of the given Config through the status subresource if it was changed
+func (r *ConfigReconciler) updateStatus(ctx context.Context, original, hohConfig *hubofhubsv1alpha1.Config) error {
+	hohConfig.Status.ObservedGeneration = hohConfig.GetGeneration()
+	setReadyConditionAndPhase(hohConfig)
+
+	if equality.Semantic.DeepEqual(original.Status, hohConfig.Status) {
+		return nil
+	}
+
+	// the Config can be gone once its finalizer is removed
+	return client.IgnoreNotFound(r.Status().Patch(ctx, hohConfig, client.MergeFrom(original)))
+}
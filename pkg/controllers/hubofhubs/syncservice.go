@@ -0,0 +1,350 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hubofhubs
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/cert"
+	"k8s.io/client-go/util/keyutil"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	hubofhubsv1alpha1 "github.com/stolostron/hub-of-hubs-operator/apis/hubofhubs/v1alpha1"
+)
+
+const (
+	syncServiceNamespace = "sync-service"
+	cssRouteName         = "sync-service-css"
+	// cssTLSSecretName is the secret with the CA, the serving certificate of the CSS and the key
+	// of the ESS passwords, it is read back to keep them stable across reconciliations
+	cssTLSSecretName       = "sync-service-css-tls"
+	defaultPollingInterval = 5
+	// cssRoutePort is the port of the CSS route, the route passes the TLS connections through to the CSS
+	cssRoutePort        = 443
+	servingCertLifetime = 365 * 24 * time.Hour
+	// certRenewBefore is the remaining lifetime under which the certificates are reissued
+	certRenewBefore = 30 * 24 * time.Hour
+	authKeySize     = 32
+)
+
+// syncServiceSettings contains the settings of the sync-service CSS in the hub-of-hubs cluster
+type syncServiceSettings struct {
+	PollingInterval uint64
+	Host            string
+	CACert          []byte
+	CAKey           []byte
+	ServingCert     []byte
+	ServingKey      []byte
+	AuthKey         []byte
+	// Users contains a line with the name and the password of the ESS of each leaf hub
+	Users []byte
+	// SecretsHash is the hash of the secrets read by the CSS
+	SecretsHash string
+}
+
+// essSettings contains the settings of the sync-service ESS in a leaf hub
+type essSettings struct {
+	PollingInterval uint64
+	CSSHost         string
+	CSSPort         int
+	CACert          []byte
+	// authKey is used to derive the password of the ESS of each leaf hub
+	authKey []byte
+}
+
+// password returns the password of the ESS of the given leaf hub
+func (s *essSettings) password(leafHub string) string {
+	return essPassword(s.authKey, leafHub)
+}
+
+// essPassword derives the password of the ESS of a leaf hub from the key of the CSS, so that the passwords
+// don't need to be stored
+func essPassword(authKey []byte, leafHub string) string {
+	mac := hmac.New(sha256.New, authKey)
+	mac.Write([]byte(leafHub))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// getSyncServiceConfig returns the settings of the sync-service transport, or nil if they are not set
+func getSyncServiceConfig(hohConfig *hubofhubsv1alpha1.Config) *hubofhubsv1alpha1.SyncServiceConfig {
+	if components := hohConfig.Spec.Components; components != nil && components.Transport != nil {
+		return components.Transport.SyncService
+	}
+	return nil
+}
+
+// getPollingInterval returns the interval in seconds of the ESS polling the CSS
+func getPollingInterval(hohConfig *hubofhubsv1alpha1.Config) uint64 {
+	if syncService := getSyncServiceConfig(hohConfig); syncService != nil && syncService.PollingInterval > 0 {
+		return syncService.PollingInterval
+	}
+	return defaultPollingInterval
+}
+
+// getSyncServiceSettings returns the settings of the CSS. The certificates and the key of the ESS passwords
+// are generated once and reused, the serving certificate is reissued when it is about to expire or when
+// it doesn't cover the host of the CSS route.
+func (r *ConfigReconciler) getSyncServiceSettings(ctx context.Context, hohConfig *hubofhubsv1alpha1.Config,
+) (*syncServiceSettings, error) {
+	settings := &syncServiceSettings{PollingInterval: getPollingInterval(hohConfig)}
+	if syncService := getSyncServiceConfig(hohConfig); syncService != nil {
+		settings.Host = syncService.Host
+	}
+
+	tlsSecret := &corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: syncServiceNamespace, Name: cssTLSSecretName},
+		tlsSecret); err != nil && !errors.IsNotFound(err) {
+		return nil, err
+	}
+
+	// the generated host of the route is only known once the route is created
+	hosts := cssServiceHosts()
+	routeHost := settings.Host
+	if routeHost == "" {
+		var err error
+		if routeHost, err = r.getCSSHost(ctx); err != nil && !errors.IsNotFound(err) && !meta.IsNoMatchError(err) {
+			return nil, err
+		}
+	}
+	if routeHost != "" {
+		hosts = append(hosts, routeHost)
+	}
+
+	caCert, caKey, err := ensureCA(tlsSecret.Data["ca.crt"], tlsSecret.Data["ca.key"])
+	if err != nil {
+		return nil, err
+	}
+	servingCert, servingKey, err := ensureServingCert(caCert, caKey, tlsSecret.Data["tls.crt"],
+		tlsSecret.Data["tls.key"], hosts)
+	if err != nil {
+		return nil, err
+	}
+	settings.CACert, settings.CAKey = caCert, caKey
+	settings.ServingCert, settings.ServingKey = servingCert, servingKey
+
+	settings.AuthKey = tlsSecret.Data["auth.key"]
+	if len(settings.AuthKey) != authKeySize {
+		settings.AuthKey = make([]byte, authKeySize)
+		if _, err := rand.Read(settings.AuthKey); err != nil {
+			return nil, err
+		}
+	}
+
+	leafHubs := &clusterv1.ManagedClusterList{}
+	if err := r.List(ctx, leafHubs, client.MatchingLabels{hubofhubsv1alpha1.LeafHubLabelKey: "true"}); err != nil {
+		return nil, err
+	}
+	var users bytes.Buffer
+	for _, leafHub := range leafHubs.Items {
+		fmt.Fprintf(&users, "%s:%s\n", leafHub.Name, essPassword(settings.AuthKey, leafHub.Name))
+	}
+	settings.Users = users.Bytes()
+
+	settings.SecretsHash = hashSecrets(settings.CACert, settings.ServingCert, settings.ServingKey, settings.Users)
+	return settings, nil
+}
+
+// getESSSettings returns the settings of the ESS of the leaf hubs, it needs the host of the CSS route
+// and the CA generated for the CSS
+func (r *ConfigReconciler) getESSSettings(ctx context.Context, hohConfig *hubofhubsv1alpha1.Config,
+) (*essSettings, error) {
+	cssHost, err := r.getCSSHost(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if cssHost == "" {
+		return nil, fmt.Errorf("the host of the sync-service CSS route is not available yet")
+	}
+
+	tlsSecret := &corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: syncServiceNamespace, Name: cssTLSSecretName},
+		tlsSecret); err != nil {
+		return nil, err
+	}
+	if len(tlsSecret.Data["ca.crt"]) == 0 || len(tlsSecret.Data["auth.key"]) == 0 {
+		return nil, fmt.Errorf("the secret %s of the sync-service CSS is not complete", cssTLSSecretName)
+	}
+
+	return &essSettings{
+		PollingInterval: getPollingInterval(hohConfig),
+		CSSHost:         cssHost,
+		CSSPort:         cssRoutePort,
+		CACert:          tlsSecret.Data["ca.crt"],
+		authKey:         tlsSecret.Data["auth.key"],
+	}, nil
+}
+
+// getCSSHost returns the host of the route of the sync-service CSS, it is empty until the route is admitted
+func (r *ConfigReconciler) getCSSHost(ctx context.Context) (string, error) {
+	cssRoute := &unstructured.Unstructured{}
+	cssRoute.SetAPIVersion("route.openshift.io/v1")
+	cssRoute.SetKind("Route")
+	if err := r.Get(ctx, types.NamespacedName{Namespace: syncServiceNamespace, Name: cssRouteName},
+		cssRoute); err != nil {
+		return "", err
+	}
+
+	host, _, err := unstructured.NestedString(cssRoute.Object, "spec", "host")
+	return host, err
+}
+
+// cssServingCertReady checks that the serving certificate of the CSS covers the host of its route,
+// so that the ESS can verify it
+func (r *ConfigReconciler) cssServingCertReady(ctx context.Context) (bool, string, error) {
+	cssHost, err := r.getCSSHost(ctx)
+	if err != nil {
+		return false, "", err
+	}
+	if cssHost == "" {
+		return false, "waiting for the host of the sync-service CSS route", nil
+	}
+
+	tlsSecret := &corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: syncServiceNamespace, Name: cssTLSSecretName},
+		tlsSecret); err != nil {
+		return false, "", err
+	}
+	certs, err := cert.ParseCertsPEM(tlsSecret.Data["tls.crt"])
+	if err != nil {
+		return false, "", err
+	}
+	if certs[0].VerifyHostname(cssHost) != nil {
+		return false, fmt.Sprintf("waiting for the serving certificate of the sync-service CSS to include %s",
+			cssHost), nil
+	}
+
+	return true, "", nil
+}
+
+// cssServiceHosts returns the host names of the CSS service
+func cssServiceHosts() []string {
+	return []string{
+		cssRouteName + "." + syncServiceNamespace + ".svc",
+		cssRouteName + "." + syncServiceNamespace + ".svc.cluster.local",
+	}
+}
+
+// ensureCA returns the given CA if it is valid for long enough, otherwise it generates a new one
+func ensureCA(caCertPEM, caKeyPEM []byte) ([]byte, []byte, error) {
+	if caCert, _, err := parseCertAndKey(caCertPEM, caKeyPEM); err == nil && caCert.IsCA &&
+		time.Until(caCert.NotAfter) > certRenewBefore {
+		return caCertPEM, caKeyPEM, nil
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, nil, err
+	}
+	// the CA generated by client-go is valid for 10 years
+	caCert, err := cert.NewSelfSignedCACert(cert.Config{CommonName: "sync-service-css-ca"}, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyPEM, err := keyutil.MarshalPrivateKeyToPEM(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: cert.CertificateBlockType, Bytes: caCert.Raw}), keyPEM, nil
+}
+
+// ensureServingCert returns the given serving certificate if it is signed by the CA, covers the hosts
+// and is valid for long enough, otherwise it issues a new one
+func ensureServingCert(caCertPEM, caKeyPEM, certPEM, keyPEM []byte, hosts []string) ([]byte, []byte, error) {
+	caCert, caKey, err := parseCertAndKey(caCertPEM, caKeyPEM)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if servingCert, _, err := parseCertAndKey(certPEM, keyPEM); err == nil &&
+		servingCert.CheckSignatureFrom(caCert) == nil && time.Until(servingCert.NotAfter) > certRenewBefore &&
+		coversHosts(servingCert, hosts) {
+		return certPEM, keyPEM, nil
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, nil, err
+	}
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject:      pkix.Name{CommonName: hosts[0]},
+		DNSNames:     hosts,
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(servingCertLifetime),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	servingKeyPEM, err := keyutil.MarshalPrivateKeyToPEM(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: cert.CertificateBlockType, Bytes: der}), servingKeyPEM, nil
+}
+
+// parseCertAndKey parses a PEM encoded certificate and its RSA key
+func parseCertAndKey(certPEM, keyPEM []byte) (*x509.Certificate, *rsa.PrivateKey, error) {
+	certs, err := cert.ParseCertsPEM(certPEM)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := keyutil.ParsePrivateKeyPEM(keyPEM)
+	if err != nil {
+		return nil, nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, nil, fmt.Errorf("the key of the certificate %s is not an RSA key", certs[0].Subject.CommonName)
+	}
+	return certs[0], rsaKey, nil
+}
+
+// coversHosts returns true if the certificate is valid for all the given hosts
+func coversHosts(certificate *x509.Certificate, hosts []string) bool {
+	for _, host := range hosts {
+		if certificate.VerifyHostname(host) != nil {
+			return false
+		}
+	}
+	return true
+}
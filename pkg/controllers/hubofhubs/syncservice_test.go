@@ -0,0 +1,152 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hubofhubs
+
+import (
+	"context"
+	"crypto/x509"
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/util/cert"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	hubofhubsv1alpha1 "github.com/stolostron/hub-of-hubs-operator/apis/hubofhubs/v1alpha1"
+	"github.com/stolostron/hub-of-hubs-operator/pkg/renderer"
+)
+
+// testLeafHub returns a managed cluster labeled as a leaf hub
+func testLeafHub(name string) *clusterv1.ManagedCluster {
+	return &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{hubofhubsv1alpha1.LeafHubLabelKey: "true"}},
+	}
+}
+
+// testCSSRoute returns the route of the CSS with the given host
+func testCSSRoute(host string) *unstructured.Unstructured {
+	route := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{"host": host},
+	}}
+	route.SetAPIVersion("route.openshift.io/v1")
+	route.SetKind("Route")
+	route.SetNamespace(syncServiceNamespace)
+	route.SetName(cssRouteName)
+	return route
+}
+
+// renderedSecret returns the rendered secret with the given name
+func renderedSecret(t *testing.T, objects []runtime.Object, name string) *corev1.Secret {
+	t.Helper()
+	for _, obj := range objects {
+		unstructuredObj, ok := obj.(*unstructured.Unstructured)
+		if !ok || unstructuredObj.GetKind() != "Secret" || unstructuredObj.GetName() != name {
+			continue
+		}
+		secret := &corev1.Secret{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(unstructuredObj.Object, secret); err != nil {
+			t.Fatal(err)
+		}
+		return secret
+	}
+	t.Fatalf("the secret %s is not rendered", name)
+	return nil
+}
+
+func TestRenderSyncService(t *testing.T) {
+	ctx := context.TODO()
+	hohConfig := newTestConfig()
+	hohConfig.Spec.Components = &hubofhubsv1alpha1.ComponentsConfig{
+		Transport: &hubofhubsv1alpha1.TransportConfig{
+			Provider:    hubofhubsv1alpha1.SyncServiceTransportProvider,
+			SyncService: &hubofhubsv1alpha1.SyncServiceConfig{PollingInterval: 10},
+		},
+	}
+	r := newTestReconciler(t, testLeafHub("hub1"), testLeafHub("hub2"), testCSSRoute("css.apps.example.com"))
+	hohRenderer := renderer.NewHoHRenderer(fs)
+
+	objects, err := r.renderTransport(ctx, hohConfig, hohRenderer)
+	if err != nil {
+		t.Fatalf("renderTransport() failed: %v", err)
+	}
+
+	args, env := containerArgsAndEnv(t, objects, "sync-service-css")
+	if len(args) != 0 {
+		t.Errorf("the CSS has the arguments %v, want none", args)
+	}
+	wantEnv := map[string]string{
+		"LISTENING_TYPE": "secure", "AUTHENTICATION_HANDLER": "file", "HTTP_POLLING_INTERVAL": "10",
+	}
+	for name, value := range wantEnv {
+		if env[name] != value {
+			t.Errorf("the environment variable %s of the CSS is %q, want %q", name, env[name], value)
+		}
+	}
+
+	// the serving certificate is signed by the generated CA for the hosts of the service and of the route
+	tlsSecret := renderedSecret(t, objects, cssTLSSecretName)
+	caCerts, err := cert.ParseCertsPEM(tlsSecret.Data["ca.crt"])
+	if err != nil {
+		t.Fatalf("failed to parse the CA: %v", err)
+	}
+	servingCerts, err := cert.ParseCertsPEM(tlsSecret.Data["tls.crt"])
+	if err != nil {
+		t.Fatalf("failed to parse the serving certificate: %v", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(caCerts[0])
+	for _, host := range append(cssServiceHosts(), "css.apps.example.com") {
+		if _, err := servingCerts[0].Verify(x509.VerifyOptions{DNSName: host, Roots: roots}); err != nil {
+			t.Errorf("the serving certificate is not valid for %s: %v", host, err)
+		}
+	}
+
+	// each leaf hub has a user with the password derived from the key
+	wantUsers := "hub1:" + essPassword(tlsSecret.Data["auth.key"], "hub1") + "\n" +
+		"hub2:" + essPassword(tlsSecret.Data["auth.key"], "hub2") + "\n"
+	if users := string(renderedSecret(t, objects, "sync-service-css-users").Data["users"]); users != wantUsers {
+		t.Errorf("the users are %q, want %q", users, wantUsers)
+	}
+
+	// the certificates and the key are kept once they are created
+	if err := r.Create(ctx, tlsSecret); err != nil {
+		t.Fatal(err)
+	}
+	objects, err = r.renderTransport(ctx, hohConfig, hohRenderer)
+	if err != nil {
+		t.Fatalf("renderTransport() failed: %v", err)
+	}
+	if got := renderedSecret(t, objects, cssTLSSecretName); !reflect.DeepEqual(got.Data, tlsSecret.Data) {
+		t.Error("the secret of the CSS is generated again")
+	}
+
+	ess, err := r.getESSSettings(ctx, hohConfig)
+	if err != nil {
+		t.Fatalf("getESSSettings() failed: %v", err)
+	}
+	if ess.PollingInterval != 10 || ess.CSSHost != "css.apps.example.com" || ess.CSSPort != cssRoutePort {
+		t.Errorf("the ESS polls %s:%d every %ds, want css.apps.example.com:%d every 10s", ess.CSSHost,
+			ess.CSSPort, ess.PollingInterval, cssRoutePort)
+	}
+	if password := ess.password("hub1"); !strings.Contains(wantUsers, "hub1:"+password+"\n") {
+		t.Errorf("the password of the ESS of hub1 is not the password of its CSS user")
+	}
+}
@@ -0,0 +1,230 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hubofhubs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	workv1 "open-cluster-management.io/api/work/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	hubofhubsv1alpha1 "github.com/stolostron/hub-of-hubs-operator/apis/hubofhubs/v1alpha1"
+	"github.com/stolostron/hub-of-hubs-operator/pkg/deployer"
+	"github.com/stolostron/hub-of-hubs-operator/pkg/renderer"
+)
+
+const (
+	// configFinalizer blocks the deletion of the Config until the hub-of-hubs components are uninstalled
+	configFinalizer = "hubofhubs.open-cluster-management.io/config-cleanup"
+	// uninstallRequeuePeriod is the period to check again the objects that are still being deleted
+	uninstallRequeuePeriod = 5 * time.Second
+)
+
+// reconcileDeletion uninstalls the hub-of-hubs components of a deleted Config and removes the finalizer
+// once all of them are gone
+func (r *ConfigReconciler) reconcileDeletion(ctx context.Context, hohConfig *hubofhubsv1alpha1.Config,
+) (ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx)
+
+	if !controllerutil.ContainsFinalizer(hohConfig, configFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	original := hohConfig.DeepCopy()
+	uninstalled, uninstallErr := r.uninstall(ctx, hohConfig)
+	if err := r.updateStatus(ctx, original, hohConfig); err != nil {
+		log.Error(err, "Failed to update Config status")
+		if uninstallErr == nil {
+			return ctrl.Result{}, err
+		}
+	}
+	if uninstallErr != nil {
+		return ctrl.Result{}, uninstallErr
+	}
+	if !uninstalled {
+		return ctrl.Result{RequeueAfter: uninstallRequeuePeriod}, nil
+	}
+
+	log.Info("Hub-of-hubs is uninstalled, removing the finalizer from Config")
+	controllerutil.RemoveFinalizer(hohConfig, configFinalizer)
+	if err := r.Update(ctx, hohConfig); err != nil && !errors.IsNotFound(err) {
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// uninstall removes the hub-of-hubs components in the reverse order of their dependencies: the manager,
+// the transport, the database and then the agents of the leaf hubs. The data-bearing objects are kept
+// unless the deletion policy is Delete. It returns true once all the components are uninstalled.
+func (r *ConfigReconciler) uninstall(ctx context.Context, hohConfig *hubofhubsv1alpha1.Config) (bool, error) {
+	hohRenderer, err := r.newRenderer(ctx)
+	if err != nil {
+		return false, err
+	}
+	hohDeployer := r.newDeployer()
+
+	inv, err := r.getInventory(ctx, hohConfig)
+	if err != nil {
+		return false, err
+	}
+
+	components := r.hubComponents()
+	for i := len(components) - 1; i >= 0; i-- {
+		component := components[i]
+		uninstalled, err := r.uninstallHubComponent(ctx, hohConfig, component, inv[component.name],
+			hohRenderer, hohDeployer)
+		if err != nil {
+			setCondition(hohConfig, component.conditionType, metav1.ConditionFalse, reasonUninstallFailed,
+				fmt.Sprintf("failed to uninstall the %s component: %v", component.name, err))
+			return false, err
+		}
+		if !uninstalled {
+			return false, nil
+		}
+	}
+
+	uninstalled, err := r.uninstallLeafHubAgents(ctx)
+	if err != nil {
+		setCondition(hohConfig, hubofhubsv1alpha1.ConditionTypeLeafHubAgents, metav1.ConditionFalse,
+			reasonUninstallFailed, fmt.Sprintf("failed to uninstall the leaf hub agents component: %v", err))
+		return false, err
+	}
+	if !uninstalled {
+		setCondition(hohConfig, hubofhubsv1alpha1.ConditionTypeLeafHubAgents, metav1.ConditionFalse,
+			reasonUninstalling, "waiting for the agents to be removed from the leaf hubs")
+		return false, nil
+	}
+	setCondition(hohConfig, hubofhubsv1alpha1.ConditionTypeLeafHubAgents, metav1.ConditionFalse,
+		reasonUninstalled, "the leaf hub agents component is uninstalled")
+	hohConfig.Status.LeafHubAgents = nil
+
+	return true, nil
+}
+
+// uninstallHubComponent deletes the objects of the given component in the reverse order of their deployment,
+// including the objects recorded in the inventory that are no longer rendered, and records the progress
+// in the component condition
+func (r *ConfigReconciler) uninstallHubComponent(ctx context.Context, hohConfig *hubofhubsv1alpha1.Config,
+	component hubComponent, recorded []objectRef, hohRenderer renderer.Renderer, hohDeployer deployer.Deployer,
+) (bool, error) {
+	log := ctrllog.FromContext(ctx)
+
+	// the objects recorded in the inventory are still deleted when the component can't be rendered anymore,
+	// e.g. the secrets of an external database were removed first
+	rendered, err := component.render(ctx, hohConfig, hohRenderer)
+	if err != nil {
+		log.Error(err, "Failed to render the component, deleting the objects of the inventory",
+			"component", component.name)
+		rendered = nil
+	}
+	refs, err := newObjectRefs(rendered)
+	if err != nil {
+		return false, err
+	}
+	var objects []runtime.Object
+	for _, ref := range subtractObjectRefs(recorded, refs) {
+		objects = append(objects, ref.toObject())
+	}
+	objects = append(objects, rendered...)
+
+	remaining, retained := 0, 0
+	for i := len(objects) - 1; i >= 0; i-- {
+		obj := objects[i]
+		if hohConfig.Spec.DeletionPolicy != hubofhubsv1alpha1.DeleteDeletionPolicy && isDataBearing(obj) {
+			retained++
+			continue
+		}
+		if err := setOwner(obj, hohConfig); err != nil {
+			return false, err
+		}
+
+		log.Info("Deleting object", objectKeysAndValues(obj)...)
+		deleted, err := hohDeployer.Undeploy(obj)
+		if err != nil {
+			return false, err
+		}
+		if !deleted {
+			remaining++
+		}
+	}
+
+	if remaining > 0 {
+		setCondition(hohConfig, component.conditionType, metav1.ConditionFalse, reasonUninstalling,
+			fmt.Sprintf("waiting for %d objects of the %s component to be deleted", remaining, component.name))
+		return false, nil
+	}
+
+	message := fmt.Sprintf("the %s component is uninstalled", component.name)
+	if retained > 0 {
+		message = fmt.Sprintf("%s, %d data-bearing objects are retained", message, retained)
+	}
+	setCondition(hohConfig, component.conditionType, metav1.ConditionFalse, reasonUninstalled, message)
+
+	return true, nil
+}
+
+// uninstallLeafHubAgents deletes the agent ManifestWorks, the work agents remove the deployed agent objects
+// from the leaf hubs before the ManifestWorks are gone. It returns true once no agent ManifestWork is left.
+func (r *ConfigReconciler) uninstallLeafHubAgents(ctx context.Context) (bool, error) {
+	log := ctrllog.FromContext(ctx)
+
+	works := &workv1.ManifestWorkList{}
+	if err := r.List(ctx, works, client.MatchingLabels{hubofhubsv1alpha1.LeafHubLabelKey: "true"}); err != nil {
+		if meta.IsNoMatchError(err) {
+			return true, nil
+		}
+		return false, err
+	}
+
+	remaining := 0
+	for i := range works.Items {
+		work := &works.Items[i]
+		if work.Name != agentManifestWorkName {
+			continue
+		}
+		remaining++
+		if work.GetDeletionTimestamp() != nil {
+			continue
+		}
+		log.Info("Deleting agent ManifestWork", "leafHub", work.Namespace)
+		if err := r.Delete(ctx, work); err != nil && !errors.IsNotFound(err) {
+			return false, err
+		}
+	}
+
+	return remaining == 0, nil
+}
+
+// isDataBearing returns true if the given object holds data that must survive the uninstallation,
+// such objects are annotated in the manifests
+func isDataBearing(obj runtime.Object) bool {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return false
+	}
+	return accessor.GetAnnotations()[dataBearingAnnotationKey] == "true"
+}
@@ -0,0 +1,99 @@
+package deployer
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ApplyDeployer is an implementation of Deployer interface that uses server-side apply,
+// it works for any kind including custom resources
+type ApplyDeployer struct {
+	client       client.Client
+	fieldManager string
+}
+
+// NewApplyDeployer creates a new ApplyDeployer that applies the objects with the given field manager
+func NewApplyDeployer(client client.Client, fieldManager string) Deployer {
+	return &ApplyDeployer{client: client, fieldManager: fieldManager}
+}
+
+// Deploy applies the object, the fields owned by other managers are not overwritten and the conflicts
+// are returned as errors
+func (d *ApplyDeployer) Deploy(obj runtime.Object) error {
+	unsObjContent, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return err
+	}
+
+	unsObj := &unstructured.Unstructured{Object: unsObjContent}
+	if skipUpdate(unsObj) {
+		foundObj := &unstructured.Unstructured{}
+		foundObj.SetGroupVersionKind(unsObj.GroupVersionKind())
+		err := d.client.Get(
+			context.TODO(),
+			types.NamespacedName{Name: unsObj.GetName(), Namespace: unsObj.GetNamespace()},
+			foundObj,
+		)
+		if err == nil {
+			return nil
+		}
+		if !errors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	// the pod template of a Job can't be updated, the Job is deleted and applied again
+	if isJob(unsObj) {
+		if err := d.deleteOutdatedJob(unsObj); err != nil {
+			return err
+		}
+	}
+
+	// the apply configuration must not carry the server populated metadata
+	unsObj.SetResourceVersion("")
+	unsObj.SetManagedFields(nil)
+	unsObj.SetCreationTimestamp(metav1.Time{})
+	unstructured.RemoveNestedField(unsObj.Object, "status")
+
+	err = d.client.Patch(context.TODO(), unsObj, client.Apply, client.FieldOwner(d.fieldManager))
+	if errors.IsConflict(err) {
+		return fmt.Errorf("failed to apply %s %s/%s, the fields are managed by other managers: %w",
+			unsObj.GetKind(), unsObj.GetNamespace(), unsObj.GetName(), err)
+	}
+	return err
+}
+
+// deleteOutdatedJob records the hash of the pod template of the desired Job and deletes the existing Job
+// when it was created from another template or has failed
+func (d *ApplyDeployer) deleteOutdatedJob(job *unstructured.Unstructured) error {
+	if err := setJobTemplateHash(job); err != nil {
+		return err
+	}
+
+	foundJob := &unstructured.Unstructured{}
+	foundJob.SetGroupVersionKind(job.GroupVersionKind())
+	err := d.client.Get(context.TODO(), types.NamespacedName{Name: job.GetName(), Namespace: job.GetNamespace()},
+		foundJob)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if !jobOutdated(job, foundJob) {
+		return nil
+	}
+	return deleteJob(d.client, foundJob)
+}
+
+// Undeploy deletes the object and returns true once it is gone
+func (d *ApplyDeployer) Undeploy(obj runtime.Object) (bool, error) {
+	return undeploy(d.client, obj)
+}
@@ -7,4 +7,6 @@ import (
 // Deployer is the interface for the kubernetes resource deployer
 type Deployer interface {
 	Deploy(obj runtime.Object) error
+	// Undeploy deletes the object, it returns true once the object doesn't exist anymore
+	Undeploy(obj runtime.Object) (bool, error)
 }
@@ -11,12 +11,18 @@ import (
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	apiequality "k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cdpov1beta1 "github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
 )
 
+const skipCreationIfExistAnnotationKey = "skip-creation-if-exist"
+
 type deployFunc func(*unstructured.Unstructured, *unstructured.Unstructured) error
 
 // HoHDeployer is an implementation of Deployer interface
@@ -36,6 +42,12 @@ func NewHoHDeployer(client client.Client) Deployer {
 		"ClusterRole":              deployer.deployClusterRole,
 		"ClusterRoleBinding":       deployer.deployClusterRoleBinding,
 		"CustomResourceDefinition": deployer.deployCRD,
+		"PostgresCluster":          deployer.deployPostgresCluster,
+		"Kafka":                    deployer.deploySpec,
+		"KafkaTopic":               deployer.deploySpec,
+		"Config":                   deployer.deploySpec,
+		"Ingress":                  deployer.deploySpec,
+		"Route":                    deployer.deployRoute,
 	}
 	return deployer
 }
@@ -48,6 +60,12 @@ func (d *HoHDeployer) Deploy(obj runtime.Object) error {
 	}
 
 	unsObj := &unstructured.Unstructured{Object: unsObjContent}
+	if isJob(unsObj) {
+		if err := setJobTemplateHash(unsObj); err != nil {
+			return err
+		}
+	}
+
 	foundObj := &unstructured.Unstructured{}
 	foundObj.SetGroupVersionKind(obj.GetObjectKind().GroupVersionKind())
 	err = d.client.Get(
@@ -63,14 +81,28 @@ func (d *HoHDeployer) Deploy(obj runtime.Object) error {
 	}
 
 	// if resource has annotation skip-creation-if-exist: true, then it will not be updated
-	metadata, ok := unsObj.Object["metadata"].(map[string]interface{})
-	if ok {
-		annotations, ok := metadata["annotations"].(map[string]interface{})
-		if ok && annotations != nil && annotations["skip-creation-if-exist"] != nil {
-			if strings.ToLower(annotations["skip-creation-if-exist"].(string)) == "true" {
-				return nil
-			}
+	if skipUpdate(unsObj) {
+		return nil
+	}
+
+	// the pod template of a Job can't be updated, the Job is created again
+	if isJob(unsObj) && jobOutdated(unsObj, foundObj) {
+		if err := deleteJob(d.client, foundObj); err != nil {
+			return err
 		}
+		return d.client.Create(context.TODO(), unsObj)
+	}
+
+	// the labels and annotations of the desired object are added to the existing object even if its kind
+	// has no deploy function, so that the objects created before can be tracked
+	if !apiequality.Semantic.DeepDerivative(unsObj.GetLabels(), foundObj.GetLabels()) ||
+		!apiequality.Semantic.DeepDerivative(unsObj.GetAnnotations(), foundObj.GetAnnotations()) {
+		foundObj.SetLabels(mergeStringMaps(foundObj.GetLabels(), unsObj.GetLabels()))
+		foundObj.SetAnnotations(mergeStringMaps(foundObj.GetAnnotations(), unsObj.GetAnnotations()))
+		if err := d.client.Update(context.TODO(), foundObj); err != nil {
+			return err
+		}
+		unsObj.SetResourceVersion(foundObj.GetResourceVersion())
 	}
 
 	deployFunction, ok := d.deployFuncs[foundObj.GetKind()]
@@ -80,6 +112,67 @@ func (d *HoHDeployer) Deploy(obj runtime.Object) error {
 	return nil
 }
 
+// Undeploy deletes the object and returns true once it is gone
+func (d *HoHDeployer) Undeploy(obj runtime.Object) (bool, error) {
+	return undeploy(d.client, obj)
+}
+
+// undeploy deletes the object and returns true once it is gone. The existing object is only deleted when it
+// carries the labels of the desired object, so that objects not created by the deployer are left untouched.
+func undeploy(c client.Client, obj runtime.Object) (bool, error) {
+	unsObjContent, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return false, err
+	}
+
+	unsObj := &unstructured.Unstructured{Object: unsObjContent}
+	foundObj := &unstructured.Unstructured{}
+	foundObj.SetGroupVersionKind(obj.GetObjectKind().GroupVersionKind())
+	err = c.Get(
+		context.TODO(),
+		types.NamespacedName{Name: unsObj.GetName(), Namespace: unsObj.GetNamespace()},
+		foundObj,
+	)
+	if err != nil {
+		// the kind is not served anymore when its CRD is removed, so there is nothing left to delete
+		if errors.IsNotFound(err) || meta.IsNoMatchError(err) {
+			return true, nil
+		}
+		return false, err
+	}
+
+	if !apiequality.Semantic.DeepDerivative(unsObj.GetLabels(), foundObj.GetLabels()) {
+		return true, nil
+	}
+	if foundObj.GetDeletionTimestamp() != nil {
+		return false, nil
+	}
+
+	err = c.Delete(context.TODO(), foundObj, client.PropagationPolicy(metav1.DeletePropagationBackground))
+	if err != nil && !errors.IsNotFound(err) {
+		return false, err
+	}
+	return errors.IsNotFound(err), nil
+}
+
+// skipUpdate returns true if the object has the annotation skip-creation-if-exist: true,
+// such an object is only created and never updated
+func skipUpdate(obj *unstructured.Unstructured) bool {
+	return strings.ToLower(obj.GetAnnotations()[skipCreationIfExistAnnotationKey]) == "true"
+}
+
+// mergeStringMaps returns a copy of the existing map with the entries of the desired map added
+func mergeStringMaps(existing, desired map[string]string) map[string]string {
+	merged := make(map[string]string, len(existing)+len(desired))
+	for key, value := range existing {
+		merged[key] = value
+	}
+	for key, value := range desired {
+		merged[key] = value
+	}
+	return merged
+}
+
 func (d *HoHDeployer) deployDeployment(desiredObj, existingObj *unstructured.Unstructured) error {
 	existingJSON, _ := existingObj.MarshalJSON()
 	existingDepoly := &appsv1.Deployment{}
@@ -237,3 +330,55 @@ func (d *HoHDeployer) deployCRD(desiredObj, existingObj *unstructured.Unstructur
 
 	return nil
 }
+
+// deployPostgresCluster updates the spec of the PostgresCluster in place, PGO rolls the changes out to the
+// existing instances, so that the replicas can be changed without recreating the data volumes
+func (d *HoHDeployer) deployPostgresCluster(desiredObj, existingObj *unstructured.Unstructured) error {
+	existingJSON, _ := existingObj.MarshalJSON()
+	existingPostgresCluster := &cdpov1beta1.PostgresCluster{}
+	err := json.Unmarshal(existingJSON, existingPostgresCluster)
+	if err != nil {
+		return err
+	}
+
+	desiredJSON, _ := desiredObj.MarshalJSON()
+	desiredPostgresCluster := &cdpov1beta1.PostgresCluster{}
+	err = json.Unmarshal(desiredJSON, desiredPostgresCluster)
+	if err != nil {
+		return err
+	}
+
+	// the whole spec is compared, so that the removed settings are removed from the PostgresCluster too
+	if !apiequality.Semantic.DeepEqual(desiredPostgresCluster.Spec, existingPostgresCluster.Spec) {
+		desiredPostgresCluster.ObjectMeta.ResourceVersion = existingPostgresCluster.ObjectMeta.ResourceVersion
+		return d.client.Update(context.TODO(), desiredPostgresCluster)
+	}
+
+	return nil
+}
+
+// deploySpec updates the spec of an object whose kind has no dedicated deploy function, such as the custom
+// resources that have no Go type in the operator. The whole spec is compared, so that the removed settings,
+// e.g. a kafka config key, are removed from the object too.
+func (d *HoHDeployer) deploySpec(desiredObj, existingObj *unstructured.Unstructured) error {
+	if !apiequality.Semantic.DeepEqual(desiredObj.Object["spec"], existingObj.Object["spec"]) {
+		desiredObj.SetResourceVersion(existingObj.GetResourceVersion())
+		return d.client.Update(context.TODO(), desiredObj)
+	}
+
+	return nil
+}
+
+// deployRoute updates the spec of a Route, the host assigned by the router is kept when the desired Route
+// has none
+func (d *HoHDeployer) deployRoute(desiredObj, existingObj *unstructured.Unstructured) error {
+	if _, found, _ := unstructured.NestedString(desiredObj.Object, "spec", "host"); !found {
+		if host, found, _ := unstructured.NestedString(existingObj.Object, "spec", "host"); found {
+			if err := unstructured.SetNestedField(desiredObj.Object, host, "spec", "host"); err != nil {
+				return err
+			}
+		}
+	}
+
+	return d.deploySpec(desiredObj, existingObj)
+}
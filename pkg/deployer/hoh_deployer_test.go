@@ -0,0 +1,210 @@
+package deployer
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	cdpov1beta1 "github.com/crunchydata/postgres-operator/pkg/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+// testPostgresCluster returns a PostgresCluster with an instance set of the given storage class and resources
+func testPostgresCluster(storageClassName string, resources corev1.ResourceRequirements) *cdpov1beta1.PostgresCluster {
+	instance := cdpov1beta1.PostgresInstanceSetSpec{
+		Name:      "pgha1",
+		Resources: resources,
+		DataVolumeClaimSpec: corev1.PersistentVolumeClaimSpec{
+			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+			},
+		},
+	}
+	if storageClassName != "" {
+		instance.DataVolumeClaimSpec.StorageClassName = &storageClassName
+	}
+	return &cdpov1beta1.PostgresCluster{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "postgres-operator.crunchydata.com/v1beta1", Kind: "PostgresCluster"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: "hoh-postgres", Name: "hoh"},
+		Spec: cdpov1beta1.PostgresClusterSpec{
+			PostgresVersion: 13,
+			InstanceSets:    []cdpov1beta1.PostgresInstanceSetSpec{instance},
+		},
+	}
+}
+
+func TestDeployPostgresCluster(t *testing.T) {
+	limits := corev1.ResourceRequirements{
+		Limits: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+	}
+
+	tests := []struct {
+		name     string
+		existing *cdpov1beta1.PostgresCluster
+		desired  *cdpov1beta1.PostgresCluster
+	}{
+		{
+			name:     "removed resources",
+			existing: testPostgresCluster("", limits),
+			desired:  testPostgresCluster("", corev1.ResourceRequirements{}),
+		},
+		{
+			name:     "removed storage class",
+			existing: testPostgresCluster("gp2", corev1.ResourceRequirements{}),
+			desired:  testPostgresCluster("", corev1.ResourceRequirements{}),
+		},
+		{
+			name:     "changed resources",
+			existing: testPostgresCluster("", corev1.ResourceRequirements{}),
+			desired:  testPostgresCluster("", limits),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheme := runtime.NewScheme()
+			if err := clientgoscheme.AddToScheme(scheme); err != nil {
+				t.Fatal(err)
+			}
+			if err := cdpov1beta1.AddToScheme(scheme); err != nil {
+				t.Fatal(err)
+			}
+			c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(tt.existing).Build()
+
+			if err := NewHoHDeployer(c).Deploy(tt.desired); err != nil {
+				t.Fatalf("Deploy() failed: %v", err)
+			}
+
+			deployed := &cdpov1beta1.PostgresCluster{}
+			if err := c.Get(context.TODO(), types.NamespacedName{Namespace: "hoh-postgres", Name: "hoh"},
+				deployed); err != nil {
+				t.Fatalf("failed to get the PostgresCluster: %v", err)
+			}
+			if got, want := deployed.Spec.InstanceSets[0], tt.desired.Spec.InstanceSets[0]; !equalInstanceSets(got, want) {
+				t.Errorf("the instance set is %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+// equalInstanceSets compares the settings of the instance sets that are changed by the tests
+func equalInstanceSets(a, b cdpov1beta1.PostgresInstanceSetSpec) bool {
+	return a.Resources.Limits.Cpu().Cmp(*b.Resources.Limits.Cpu()) == 0 &&
+		(a.DataVolumeClaimSpec.StorageClassName == nil) == (b.DataVolumeClaimSpec.StorageClassName == nil)
+}
+
+// testKafka returns a Kafka custom resource with the given kafka config
+func testKafka(config map[string]interface{}) *unstructured.Unstructured {
+	kafka := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"kafka": map[string]interface{}{"replicas": int64(3), "config": config},
+		},
+	}}
+	kafka.SetAPIVersion("kafka.strimzi.io/v1beta2")
+	kafka.SetKind("Kafka")
+	kafka.SetNamespace("kafka")
+	kafka.SetName("kafka-brokers-cluster")
+	return kafka
+}
+
+func TestDeploySpec(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing map[string]interface{}
+		desired  map[string]interface{}
+	}{
+		{
+			name:     "removed config key",
+			existing: map[string]interface{}{"log.retention.hours": int64(24), "min.insync.replicas": int64(2)},
+			desired:  map[string]interface{}{"min.insync.replicas": int64(2)},
+		},
+		{
+			name:     "changed config key",
+			existing: map[string]interface{}{"min.insync.replicas": int64(1)},
+			desired:  map[string]interface{}{"min.insync.replicas": int64(2)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			desired, existing := testKafka(tt.desired), testKafka(tt.existing)
+			c := fake.NewClientBuilder().WithScheme(runtime.NewScheme()).WithObjects(existing).Build()
+
+			if err := NewHoHDeployer(c).Deploy(desired); err != nil {
+				t.Fatalf("Deploy() failed: %v", err)
+			}
+
+			deployed := testKafka(nil)
+			if err := c.Get(context.TODO(), types.NamespacedName{Namespace: "kafka", Name: "kafka-brokers-cluster"},
+				deployed); err != nil {
+				t.Fatalf("failed to get the Kafka: %v", err)
+			}
+			config, _, _ := unstructured.NestedMap(deployed.Object, "spec", "kafka", "config")
+			if !reflect.DeepEqual(config, tt.desired) {
+				t.Errorf("the kafka config is %v, want %v", config, tt.desired)
+			}
+		})
+	}
+}
+
+// testRoute returns the Route of the manager with the given host and path
+func testRoute(host, path string) *unstructured.Unstructured {
+	route := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{"path": path},
+	}}
+	if host != "" {
+		route.Object["spec"].(map[string]interface{})["host"] = host
+	}
+	route.SetAPIVersion("route.openshift.io/v1")
+	route.SetKind("Route")
+	route.SetNamespace("open-cluster-management")
+	route.SetName("hub-of-hubs-manager")
+	return route
+}
+
+func TestDeployRoute(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing *unstructured.Unstructured
+		desired  *unstructured.Unstructured
+		want     *unstructured.Unstructured
+	}{
+		{
+			name:     "assigned host is kept",
+			existing: testRoute("hoh.apps.example.com", "/"),
+			desired:  testRoute("", "/global-hub-api"),
+			want:     testRoute("hoh.apps.example.com", "/global-hub-api"),
+		},
+		{
+			name:     "given host",
+			existing: testRoute("hoh.apps.example.com", "/"),
+			desired:  testRoute("api.example.com", "/"),
+			want:     testRoute("api.example.com", "/"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewClientBuilder().WithScheme(runtime.NewScheme()).WithObjects(tt.existing).Build()
+
+			if err := NewHoHDeployer(c).Deploy(tt.desired); err != nil {
+				t.Fatalf("Deploy() failed: %v", err)
+			}
+
+			deployed := testRoute("", "")
+			if err := c.Get(context.TODO(), types.NamespacedName{Namespace: "open-cluster-management",
+				Name: "hub-of-hubs-manager"}, deployed); err != nil {
+				t.Fatalf("failed to get the Route: %v", err)
+			}
+			if !reflect.DeepEqual(deployed.Object["spec"], tt.want.Object["spec"]) {
+				t.Errorf("the spec is %v, want %v", deployed.Object["spec"], tt.want.Object["spec"])
+			}
+		})
+	}
+}
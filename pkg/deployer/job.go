@@ -0,0 +1,70 @@
+package deployer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// jobTemplateHashAnnotationKey records the hash of the pod template of a Job, the template of a Job is immutable,
+// so the Job is recreated when the rendered template changes
+const jobTemplateHashAnnotationKey = "hubofhubs.open-cluster-management.io/template-hash"
+
+func isJob(obj *unstructured.Unstructured) bool {
+	gvk := obj.GroupVersionKind()
+	return gvk.Group == "batch" && gvk.Kind == "Job"
+}
+
+// setJobTemplateHash records the hash of the pod template in the annotations of the desired Job
+func setJobTemplateHash(job *unstructured.Unstructured) error {
+	template, _, err := unstructured.NestedFieldNoCopy(job.Object, "spec", "template")
+	if err != nil {
+		return err
+	}
+	templateJSON, err := json.Marshal(template)
+	if err != nil {
+		return err
+	}
+	hash := sha256.Sum256(templateJSON)
+
+	annotations := job.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[jobTemplateHashAnnotationKey] = hex.EncodeToString(hash[:])
+	job.SetAnnotations(annotations)
+	return nil
+}
+
+// jobOutdated returns true if the existing Job was created from another pod template or has failed,
+// a failed Job is run again
+func jobOutdated(desired, existing *unstructured.Unstructured) bool {
+	if existing.GetAnnotations()[jobTemplateHashAnnotationKey] !=
+		desired.GetAnnotations()[jobTemplateHashAnnotationKey] {
+		return true
+	}
+
+	conditions, _, _ := unstructured.NestedSlice(existing.Object, "status", "conditions")
+	for _, condition := range conditions {
+		fields, ok := condition.(map[string]interface{})
+		if ok && fields["type"] == "Failed" && fields["status"] == "True" {
+			return true
+		}
+	}
+	return false
+}
+
+// deleteJob deletes the existing Job together with its pods, so that it can be created again
+func deleteJob(c client.Client, existing *unstructured.Unstructured) error {
+	err := c.Delete(context.TODO(), existing, client.PropagationPolicy(metav1.DeletePropagationBackground))
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package deployer
+
+import (
+	"context"
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// testJob returns the rendered postgres-init Job with the given image
+func testJob(t *testing.T, image string) *unstructured.Unstructured {
+	t.Helper()
+	job := &batchv1.Job{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "batch/v1", Kind: "Job"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: "hoh-postgres", Name: "postgres-init"},
+		Spec: batchv1.JobSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers:    []corev1.Container{{Name: "initial-db", Image: image}},
+					RestartPolicy: corev1.RestartPolicyNever,
+				},
+			},
+		},
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &unstructured.Unstructured{Object: content}
+}
+
+func TestDeployJob(t *testing.T) {
+	failed := []interface{}{map[string]interface{}{"type": "Failed", "status": "True"}}
+	completed := []interface{}{map[string]interface{}{"type": "Complete", "status": "True"}}
+
+	tests := []struct {
+		name          string
+		existingImage string
+		conditions    []interface{}
+		desiredImage  string
+		wantRecreated bool
+	}{
+		{
+			name:          "unchanged job",
+			existingImage: "init:v1",
+			conditions:    completed,
+			desiredImage:  "init:v1",
+		},
+		{
+			name:          "changed pod template",
+			existingImage: "init:v1",
+			conditions:    completed,
+			desiredImage:  "init:v2",
+			wantRecreated: true,
+		},
+		{
+			name:          "failed job",
+			existingImage: "init:v1",
+			conditions:    failed,
+			desiredImage:  "init:v1",
+			wantRecreated: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			existing := testJob(t, tt.existingImage)
+			if err := setJobTemplateHash(existing); err != nil {
+				t.Fatal(err)
+			}
+			if err := unstructured.SetNestedSlice(existing.Object, tt.conditions, "status", "conditions"); err != nil {
+				t.Fatal(err)
+			}
+			c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(existing).Build()
+
+			if err := NewHoHDeployer(c).Deploy(testJob(t, tt.desiredImage)); err != nil {
+				t.Fatalf("Deploy() failed: %v", err)
+			}
+
+			job := &batchv1.Job{}
+			if err := c.Get(context.TODO(), types.NamespacedName{Namespace: "hoh-postgres", Name: "postgres-init"},
+				job); err != nil {
+				t.Fatalf("failed to get the job: %v", err)
+			}
+			if image := job.Spec.Template.Spec.Containers[0].Image; image != tt.desiredImage {
+				t.Errorf("the job runs %s, want %s", image, tt.desiredImage)
+			}
+			// the status of a job that is created again is empty
+			if recreated := len(job.Status.Conditions) == 0; recreated != tt.wantRecreated {
+				t.Errorf("the job is recreated: %t, want %t", recreated, tt.wantRecreated)
+			}
+		})
+	}
+}
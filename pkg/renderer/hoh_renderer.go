@@ -3,13 +3,11 @@ package renderer
 import (
 	"bufio"
 	"bytes"
-	"embed"
 	"fmt"
 	"io"
 	"io/fs"
 	"strings"
 
-	"github.com/openshift/library-go/pkg/assets"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	yamlserializer "k8s.io/apimachinery/pkg/runtime/serializer/yaml"
@@ -18,12 +16,13 @@ import (
 
 // HoHRenderer is an implementation of the Renderer interface for hub-of-hubs scenario
 type HoHRenderer struct {
-	manifestFS embed.FS
+	manifestFS fs.FS
 	decoder    runtime.Decoder
 }
 
-// NewHoHRenderer create a HoHRenderer with given filesystem
-func NewHoHRenderer(manifestFS embed.FS) Renderer {
+// NewHoHRenderer create a HoHRenderer with given filesystem, such as the embedded manifests
+// or an overlay of them created by NewOverlayFS
+func NewHoHRenderer(manifestFS fs.FS) Renderer {
 	return &HoHRenderer{
 		manifestFS: manifestFS,
 		decoder:    yamlserializer.NewDecodingSerializer(unstructured.UnstructuredJSONScheme),
@@ -35,13 +34,32 @@ func (r *HoHRenderer) Render(component string, getConfigValuesFunc GetConfigValu
 }
 
 func (r *HoHRenderer) RenderWithFilter(component, filter string, getConfigValuesFunc GetConfigValuesFunc) ([]runtime.Object, error) {
-	var objects []runtime.Object
-
 	configValues, err := getConfigValuesFunc(component)
 	if err != nil {
-		return objects, err
+		return nil, err
 	}
 
+	return r.renderTemplates(component, filter, configValues)
+}
+
+func (r *HoHRenderer) RenderForCluster(cluster, component string, getClusterConfigValuesFunc GetClusterConfigValuesFunc) ([]runtime.Object, error) {
+	return r.RenderForClusterWithFilter(cluster, component, "", getClusterConfigValuesFunc)
+}
+
+func (r *HoHRenderer) RenderForClusterWithFilter(cluster, component, filter string, getClusterConfigValuesFunc GetClusterConfigValuesFunc) ([]runtime.Object, error) {
+	configValues, err := getClusterConfigValuesFunc(cluster, component)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.renderTemplates(component, filter, configValues)
+}
+
+// renderTemplates renders the template files of the component that match the filter with the given values
+// and decodes the objects of every rendered file
+func (r *HoHRenderer) renderTemplates(component, filter string, configValues interface{}) ([]runtime.Object, error) {
+	var objects []runtime.Object
+
 	templateFiles, err := getTemplateFiles(r.manifestFS, component, filter)
 	if err != nil {
 		return objects, err
@@ -51,7 +69,7 @@ func (r *HoHRenderer) RenderWithFilter(component, filter string, getConfigValues
 	}
 
 	for _, template := range templateFiles {
-		templateContent, err := r.manifestFS.ReadFile(template)
+		templateContent, err := fs.ReadFile(r.manifestFS, template)
 		if err != nil {
 			return objects, err
 		}
@@ -60,77 +78,60 @@ func (r *HoHRenderer) RenderWithFilter(component, filter string, getConfigValues
 			continue
 		}
 
-		raw := assets.MustCreateAssetFromTemplate(template, templateContent, configValues).Data
-		yamlReader := yaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(raw)))
-		for {
-			b, err := yamlReader.Read()
-			if err == io.EOF {
-				break
-			}
-			if err != nil {
-				return objects, err
-			}
-			if len(b) != 0 {
-				object, _, err := r.decoder.Decode(b, nil, nil)
-				if err != nil {
-					if runtime.IsMissingKind(err) {
-						continue
-					}
-					return objects, err
-				}
-				objects = append(objects, object)
-			}
+		raw, err := renderTemplate(template, templateContent, configValues)
+		if err != nil {
+			return objects, err
+		}
+		templateObjects, err := r.decodeObjects(raw)
+		if err != nil {
+			return objects, fmt.Errorf("failed to decode the template %s: %w", template, err)
 		}
+		objects = append(objects, templateObjects...)
 	}
 
 	return objects, nil
 }
 
-func (r *HoHRenderer) RenderForCluster(cluster, component string, getClusterConfigValuesFunc GetClusterConfigValuesFunc) ([]runtime.Object, error) {
-	return r.RenderForClusterWithFilter(cluster, component, "", getClusterConfigValuesFunc)
-}
-
-func (r *HoHRenderer) RenderForClusterWithFilter(cluster, component, filter string, getClusterConfigValuesFunc GetClusterConfigValuesFunc) ([]runtime.Object, error) {
+// decodeObjects decodes every document of a rendered template, the items of a List are returned as separate
+// objects and the empty documents, such as the ones left by a condition of the template, are skipped
+func (r *HoHRenderer) decodeObjects(raw []byte) ([]runtime.Object, error) {
 	var objects []runtime.Object
 
-	configValues, err := getClusterConfigValuesFunc(cluster, component)
-	if err != nil {
-		return objects, err
-	}
-
-	templateFiles, err := getTemplateFiles(r.manifestFS, component, filter)
-	if err != nil {
-		return objects, err
-	}
-	if len(templateFiles) == 0 {
-		return objects, fmt.Errorf("no template files found")
-	}
-
-	for _, template := range templateFiles {
-		templateContent, err := r.manifestFS.ReadFile(template)
+	yamlReader := yaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(raw)))
+	for {
+		b, err := yamlReader.Read()
+		if err == io.EOF {
+			break
+		}
 		if err != nil {
 			return objects, err
 		}
-
-		if len(templateContent) == 0 {
+		if len(bytes.TrimSpace(b)) == 0 {
 			continue
 		}
 
-		raw := assets.MustCreateAssetFromTemplate(template, templateContent, configValues).Data
-		object, _, err := r.decoder.Decode(raw, nil, nil)
+		object, _, err := r.decoder.Decode(b, nil, nil)
 		if err != nil {
 			if runtime.IsMissingKind(err) {
 				continue
 			}
 			return objects, err
 		}
-		objects = append(objects, object)
+
+		list, ok := object.(*unstructured.UnstructuredList)
+		if !ok {
+			objects = append(objects, object)
+			continue
+		}
+		for i := range list.Items {
+			objects = append(objects, &list.Items[i])
+		}
 	}
 
 	return objects, nil
 }
 
-func getTemplateFiles(manifestFS embed.FS, dir, filter string) ([]string, error) {
+func getTemplateFiles(manifestFS fs.FS, dir, filter string) ([]string, error) {
 	files, err := getFiles(manifestFS)
 	if err != nil {
 		return nil, err
@@ -139,9 +140,12 @@ func getTemplateFiles(manifestFS embed.FS, dir, filter string) ([]string, error)
 		return files, nil
 	}
 
+	// the separator keeps a directory from matching the directories that it is a prefix of,
+	// e.g. manifests/database and manifests/database-init
+	dirPrefix := strings.TrimSuffix(dir, "/") + "/"
 	var templateFiles []string
 	for _, file := range files {
-		if strings.HasPrefix(file, dir) && strings.Contains(file, filter) {
+		if strings.HasPrefix(file, dirPrefix) && strings.Contains(strings.TrimPrefix(file, dirPrefix), filter) {
 			templateFiles = append(templateFiles, file)
 		}
 	}
@@ -149,7 +153,7 @@ func getTemplateFiles(manifestFS embed.FS, dir, filter string) ([]string, error)
 	return templateFiles, nil
 }
 
-func getFiles(manifestFS embed.FS) ([]string, error) {
+func getFiles(manifestFS fs.FS) ([]string, error) {
 	var files []string
 	err := fs.WalkDir(manifestFS, ".", func(file string, d fs.DirEntry, err error) error {
 		if err != nil {
@@ -0,0 +1,200 @@
+package renderer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// configMap returns a template of a ConfigMap with the given name
+func configMap(name string) *fstest.MapFile {
+	return &fstest.MapFile{Data: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: " + name + "\n")}
+}
+
+// objectNames returns the names of the given objects in order
+func objectNames(t *testing.T, objects []runtime.Object) []string {
+	t.Helper()
+	names := []string{}
+	for _, obj := range objects {
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			t.Fatalf("failed to access the object %v: %v", obj, err)
+		}
+		names = append(names, accessor.GetName())
+	}
+	return names
+}
+
+func TestRenderOnlyComponentDirectory(t *testing.T) {
+	manifestFS := fstest.MapFS{
+		"manifests/database/postgres.yaml":          configMap("database"),
+		"manifests/database-init/job.yaml":          configMap("database-init"),
+		"manifests/database-secrets/secrets.yaml":   configMap("database-secrets"),
+		"manifests/transport/kafka/kafka.yaml":      configMap("kafka"),
+		"manifests/transport/sync-service/css.yaml": configMap("sync-service"),
+	}
+
+	tests := []struct {
+		component string
+		want      []string
+	}{
+		{component: "manifests/database", want: []string{"database"}},
+		{component: "manifests/database-init", want: []string{"database-init"}},
+		{component: "manifests/database-secrets", want: []string{"database-secrets"}},
+		{component: "manifests/transport/kafka", want: []string{"kafka"}},
+		{component: "manifests/transport/sync-service", want: []string{"sync-service"}},
+		{component: "manifests/transport", want: []string{"kafka", "sync-service"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.component, func(t *testing.T) {
+			objects, err := NewHoHRenderer(manifestFS).Render(tt.component, func(string) (interface{}, error) {
+				return nil, nil
+			})
+			if err != nil {
+				t.Fatalf("failed to render %s: %v", tt.component, err)
+			}
+			if got := objectNames(t, objects); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("rendered %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderWithFilter(t *testing.T) {
+	manifestFS := fstest.MapFS{
+		"manifests/agent/agent-deployment.yaml": configMap("agent"),
+		"manifests/agent/ess-deployment.yaml":   configMap("ess"),
+	}
+
+	objects, err := NewHoHRenderer(manifestFS).RenderForClusterWithFilter("hub1", "manifests/agent", "agent-",
+		func(cluster, component string) (interface{}, error) {
+			return nil, nil
+		})
+	if err != nil {
+		t.Fatalf("failed to render: %v", err)
+	}
+	if got, want := objectNames(t, objects), []string{"agent"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("rendered %v, want %v", got, want)
+	}
+}
+
+func TestRenderTemplateErrors(t *testing.T) {
+	type values struct {
+		Name string
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		values   interface{}
+		wantErr  string
+	}{
+		{
+			name:     "missing field of a struct",
+			template: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: {{.LeafHubID}}\n",
+			values:   values{Name: "hub1"},
+			wantErr:  "manifests/agent/configmap.yaml:4:10: executing \"manifests/agent/configmap.yaml\" at <.LeafHubID>",
+		},
+		{
+			name:     "missing key of a map",
+			template: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: {{.Name}}\n",
+			values:   map[string]interface{}{},
+			wantErr:  `manifests/agent/configmap.yaml:4:10: executing "manifests/agent/configmap.yaml" at <.Name>: map has no entry for key "Name"`,
+		},
+		{
+			name:     "template that can't be parsed",
+			template: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: {{.Name}\n",
+			values:   values{Name: "hub1"},
+			wantErr:  "manifests/agent/configmap.yaml:4",
+		},
+		{
+			name:     "rendered object that can't be decoded",
+			template: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: [{{.Name}}\n",
+			values:   values{Name: "hub1"},
+			wantErr:  "failed to decode the template manifests/agent/configmap.yaml",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifestFS := fstest.MapFS{"manifests/agent/configmap.yaml": {Data: []byte(tt.template)}}
+			_, err := NewHoHRenderer(manifestFS).RenderForCluster("hub1", "manifests/agent",
+				func(cluster, component string) (interface{}, error) {
+					return tt.values, nil
+				})
+			if err == nil {
+				t.Fatalf("expected an error containing %q", tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error %q doesn't contain %q", err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRenderDocuments(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     []string
+	}{
+		{
+			name:     "single document",
+			template: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n",
+			want:     []string{"a"},
+		},
+		{
+			name: "documents with empty ones",
+			template: "---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n---\n\n---\n" +
+				"{{- if .Disabled }}\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: disabled\n{{- end }}\n" +
+				"---\n# only a comment\n---\napiVersion: v1\nkind: Secret\nmetadata:\n  name: b\n---\n",
+			want: []string{"a", "b"},
+		},
+		{
+			name: "list",
+			template: "apiVersion: v1\nkind: List\nitems:\n" +
+				"- apiVersion: v1\n  kind: ConfigMap\n  metadata:\n    name: a\n" +
+				"- apiVersion: v1\n  kind: Secret\n  metadata:\n    name: b\n" +
+				"---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: c\n",
+			want: []string{"a", "b", "c"},
+		},
+		{
+			name:     "empty list",
+			template: "apiVersion: v1\nkind: List\nitems: []\n",
+			want:     []string{},
+		},
+	}
+	for _, tt := range tests {
+		manifestFS := fstest.MapFS{"manifests/component/objects.yaml": {Data: []byte(tt.template)}}
+		values := struct{ Disabled bool }{}
+
+		// both the render paths decode the documents the same way
+		t.Run(tt.name, func(t *testing.T) {
+			objects, err := NewHoHRenderer(manifestFS).Render("manifests/component",
+				func(component string) (interface{}, error) {
+					return values, nil
+				})
+			if err != nil {
+				t.Fatalf("failed to render: %v", err)
+			}
+			if got := objectNames(t, objects); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Render() returned %v, want %v", got, tt.want)
+			}
+		})
+		t.Run(tt.name+" for cluster", func(t *testing.T) {
+			objects, err := NewHoHRenderer(manifestFS).RenderForCluster("hub1", "manifests/component",
+				func(cluster, component string) (interface{}, error) {
+					return values, nil
+				})
+			if err != nil {
+				t.Fatalf("failed to render: %v", err)
+			}
+			if got := objectNames(t, objects); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RenderForCluster() returned %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
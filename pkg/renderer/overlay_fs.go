@@ -0,0 +1,87 @@
+package renderer
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"sort"
+)
+
+// overlayFS is a read-only filesystem made of layers, a file is read from the first layer that has it
+type overlayFS struct {
+	layers []fs.FS
+}
+
+// NewOverlayFS returns a filesystem that reads the files of the overlays on top of the base filesystem.
+// The last overlay has the highest priority, and the entries of the directories of all the layers are merged,
+// so that an overlay can both replace and add templates. An empty file in an overlay disables the template.
+func NewOverlayFS(base fs.FS, overlays ...fs.FS) fs.FS {
+	layers := make([]fs.FS, 0, len(overlays)+1)
+	for i := len(overlays) - 1; i >= 0; i-- {
+		layers = append(layers, overlays[i])
+	}
+	return &overlayFS{layers: append(layers, base)}
+}
+
+func (o *overlayFS) Open(name string) (fs.File, error) {
+	for _, layer := range o.layers {
+		file, err := layer.Open(name)
+		if err == nil {
+			return file, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+func (o *overlayFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	found := false
+	entries := map[string]fs.DirEntry{}
+	for _, layer := range o.layers {
+		layerEntries, err := fs.ReadDir(layer, name)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		found = true
+		for _, entry := range layerEntries {
+			if entry, err = resolveSymlink(layer, path.Join(name, entry.Name()), entry); err != nil {
+				return nil, err
+			}
+			// a directory is merged with the directories of the other layers, it isn't shadowed by a file
+			if existing, ok := entries[entry.Name()]; !ok || (!existing.IsDir() && entry.IsDir()) {
+				entries[entry.Name()] = entry
+			}
+		}
+	}
+	if !found {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	merged := make([]fs.DirEntry, 0, len(entries))
+	for _, entry := range entries {
+		merged = append(merged, entry)
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Name() < merged[j].Name() })
+	return merged, nil
+}
+
+// resolveSymlink returns the entry of the file that a symbolic link points to, e.g. a ConfigMap volume mounted
+// as the overrides directory has symbolic links to the ..data directory, a broken link is returned as it is
+func resolveSymlink(layer fs.FS, name string, entry fs.DirEntry) (fs.DirEntry, error) {
+	if entry.Type()&fs.ModeSymlink == 0 {
+		return entry, nil
+	}
+	info, err := fs.Stat(layer, name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return entry, nil
+		}
+		return nil, err
+	}
+	return fs.FileInfoToDirEntry(info), nil
+}
@@ -0,0 +1,103 @@
+package renderer
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestOverlayFS(t *testing.T) {
+	base := fstest.MapFS{
+		"manifests/manager/deployment.yaml": {Data: []byte("base deployment")},
+		"manifests/manager/service.yaml":    {Data: []byte("base service")},
+		"manifests/manager/ingress.yaml":    {Data: []byte("base ingress")},
+	}
+	directory := fstest.MapFS{
+		"manifests/manager/deployment.yaml": {Data: []byte("directory deployment")},
+		"manifests/manager/service.yaml":    {Data: []byte("directory service")},
+		"manifests/manager/route.yaml":      {Data: []byte("directory route")},
+	}
+	configMap := fstest.MapFS{
+		"manifests/manager/deployment.yaml": {Data: []byte("configmap deployment")},
+		"manifests/manager/ingress.yaml":    {Data: []byte{}},
+	}
+	overlay := NewOverlayFS(base, directory, configMap)
+
+	files, err := getFiles(overlay)
+	if err != nil {
+		t.Fatalf("failed to list the files: %v", err)
+	}
+	wantFiles := []string{
+		"manifests/manager/deployment.yaml",
+		"manifests/manager/ingress.yaml",
+		"manifests/manager/route.yaml",
+		"manifests/manager/service.yaml",
+	}
+	if !reflect.DeepEqual(files, wantFiles) {
+		t.Errorf("listed %v, want %v", files, wantFiles)
+	}
+
+	for file, want := range map[string]string{
+		// the last overlay has the highest priority
+		"manifests/manager/deployment.yaml": "configmap deployment",
+		"manifests/manager/service.yaml":    "directory service",
+		"manifests/manager/route.yaml":      "directory route",
+		"manifests/manager/ingress.yaml":    "",
+	} {
+		content, err := fs.ReadFile(overlay, file)
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", file, err)
+		}
+		if string(content) != want {
+			t.Errorf("read %q from %s, want %q", content, file, want)
+		}
+	}
+
+	if _, err := fs.ReadFile(overlay, "manifests/manager/missing.yaml"); err == nil {
+		t.Errorf("expected an error reading a missing file")
+	}
+}
+
+func TestOverlayFSWithConfigMapVolume(t *testing.T) {
+	base := fstest.MapFS{
+		"manifests/manager/deployment.yaml": configMap("base-deployment"),
+		"manifests/manager/service.yaml":    configMap("base-service"),
+		"manifests/database/postgres.yaml":  configMap("base-postgres"),
+	}
+
+	// the kubelet mounts the keys of a ConfigMap as symbolic links to the ..data link of the current version
+	dir := t.TempDir()
+	version := filepath.Join(dir, "..2022_06_01_10_00_00.000000001")
+	if err := os.MkdirAll(filepath.Join(version, "manifests", "manager"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(version, "manifests", "manager", "deployment.yaml"),
+		configMap("override-deployment").Data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Base(version), filepath.Join(dir, "..data")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join("..data", "manifests"), filepath.Join(dir, "manifests")); err != nil {
+		t.Fatal(err)
+	}
+
+	hohRenderer := NewHoHRenderer(NewOverlayFS(base, os.DirFS(dir)))
+	for component, want := range map[string][]string{
+		"manifests/manager":  {"override-deployment", "base-service"},
+		"manifests/database": {"base-postgres"},
+	} {
+		objects, err := hohRenderer.Render(component, func(string) (interface{}, error) {
+			return nil, nil
+		})
+		if err != nil {
+			t.Fatalf("failed to render %s: %v", component, err)
+		}
+		if got := objectNames(t, objects); !reflect.DeepEqual(got, want) {
+			t.Errorf("rendered %v for %s, want %v", got, component, want)
+		}
+	}
+}
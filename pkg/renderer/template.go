@@ -0,0 +1,39 @@
+package renderer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"text/template"
+)
+
+// templateFuncs are the functions available in the templates, they match the functions of the library-go assets
+// that the templates were written for
+var templateFuncs = template.FuncMap{
+	"base64": base64Encode,
+	"indent": indent,
+}
+
+// renderTemplate executes the template with the given values. A reference to a missing key is an error
+// instead of "<no value>", and the errors of text/template name the template file and line.
+func renderTemplate(name string, content []byte, values interface{}) ([]byte, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(string(content))
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, values); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func base64Encode(v []byte) string {
+	return base64.StdEncoding.EncodeToString(v)
+}
+
+func indent(indention int, v []byte) string {
+	newline := "\n" + strings.Repeat(" ", indention)
+	return strings.Replace(string(v), "\n", newline, -1)
+}